@@ -0,0 +1,178 @@
+package gonionvet
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const gonionPath = "github.com/CoreyKaylor/gonion"
+
+//Analyzer reports common mistakes when composing routes and middleware with gonion
+var Analyzer = &analysis.Analyzer{
+	Name:     "gonionvet",
+	Doc:      "report misuse of gonion's Composer, RouteConstraint and MiddlewareOptions",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+//patternMethods are the Composer methods whose first argument is a path pattern
+var patternMethods = map[string]bool{
+	"Sub": true, "Get": true, "Post": true, "Put": true, "Patch": true, "Delete": true,
+}
+
+//registrationMethods are the Composer methods that register something relative
+//to the composer's path
+var registrationMethods = map[string]bool{
+	"Sub": true, "Use": true, "Only": true, "Get": true, "Post": true, "Put": true,
+	"Patch": true, "Delete": true, "Handle": true, "HandleRegistered": true, "HandleContextual": true,
+	"Skip": true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	nodes := []ast.Node{(*ast.CallExpr)(nil), (*ast.ExprStmt)(nil)}
+	inspect.Preorder(nodes, func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.ExprStmt:
+			checkDiscarded(pass, node)
+		case *ast.CallExpr:
+			receiver, method := gonionMethod(pass, node)
+			switch {
+			case receiver == "Composer":
+				checkPattern(pass, node, method)
+				if method == "Sub" {
+					checkOuterComposer(pass, node)
+				}
+			case receiver == "RouteConstraint" && (method == "Use" || method == "Skip"):
+				checkUnconstrained(pass, node, method)
+			}
+		}
+	})
+	return nil, nil
+}
+
+//checkDiscarded reports statements that build middleware options or a constraint
+//without registering anything, such as g.Use() or g.Only().Post().
+func checkDiscarded(pass *analysis.Pass, stmt *ast.ExprStmt) {
+	call, ok := stmt.X.(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	switch gonionType(pass.TypesInfo.TypeOf(call)) {
+	case "MiddlewareOptions":
+		pass.Reportf(call.Pos(), "middleware options are discarded, so nothing is registered; chain .Func, .Handler or .ChainLink")
+	case "RouteConstraint":
+		pass.Reportf(call.Pos(), "route constraint is discarded, so nothing is registered; chain .Use() or .Skip")
+	}
+}
+
+//checkPattern reports patterns that don't start with a slash, which are silently
+//concatenated onto the parent's path such as /api + users = /apiusers.
+func checkPattern(pass *analysis.Pass, call *ast.CallExpr, method string) {
+	index := 0
+	switch {
+	case patternMethods[method]:
+	case method == "Handle" || method == "HandleRegistered" || method == "HandleContextual":
+		index = 1
+	default:
+		return
+	}
+	if len(call.Args) <= index {
+		return
+	}
+	value := pass.TypesInfo.Types[call.Args[index]].Value
+	if value == nil || value.Kind() != constant.String {
+		return
+	}
+	pattern := constant.StringVal(value)
+	if pattern != "" && !strings.HasPrefix(pattern, "/") {
+		pass.Reportf(call.Args[index].Pos(), "%s pattern %s should start with /", method, strconv.Quote(pattern))
+	}
+}
+
+//checkOuterComposer reports registrations inside a Sub func that are made on a
+//composer from outside of it, which don't get the Sub's path or middleware.
+func checkOuterComposer(pass *analysis.Pass, call *ast.CallExpr) {
+	if len(call.Args) != 2 {
+		return
+	}
+	lit, ok := call.Args[1].(*ast.FuncLit)
+	if !ok || len(lit.Type.Params.List) != 1 || len(lit.Type.Params.List[0].Names) != 1 {
+		return
+	}
+	inner := lit.Type.Params.List[0].Names[0].Name
+	ast.Inspect(lit.Body, func(node ast.Node) bool {
+		if nested, ok := node.(*ast.FuncLit); ok && nested != lit {
+			return false
+		}
+		selector, ok := node.(*ast.SelectorExpr)
+		if !ok || !registrationMethods[selector.Sel.Name] {
+			return true
+		}
+		ident, ok := selector.X.(*ast.Ident)
+		if !ok || gonionType(pass.TypesInfo.TypeOf(ident)) != "Composer" {
+			return true
+		}
+		object := pass.TypesInfo.Uses[ident]
+		if object != nil && !within(object.Pos(), lit) {
+			pass.Reportf(selector.Pos(), "%s.%s is called on the outer composer inside Sub, so it doesn't apply to the Sub's path; use %s.%s",
+				ident.Name, selector.Sel.Name, inner, selector.Sel.Name)
+		}
+		return true
+	})
+}
+
+//checkUnconstrained reports Only().Use() and Only().Skip without a constraint in
+//between, which panics while building the routes.
+func checkUnconstrained(pass *analysis.Pass, call *ast.CallExpr, method string) {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	receiver, ok := selector.X.(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	if typ, name := gonionMethod(pass, receiver); typ == "Composer" && name == "Only" {
+		pass.Reportf(selector.Sel.Pos(), "Only() has no constraint before %s; add one such as .Get() or .When(...)", method)
+	}
+}
+
+func within(pos token.Pos, node ast.Node) bool {
+	return pos >= node.Pos() && pos < node.End()
+}
+
+//gonionMethod returns the gonion type and method name when the call is a method
+//call on one of gonion's types.
+func gonionMethod(pass *analysis.Pass, call *ast.CallExpr) (string, string) {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", ""
+	}
+	selection, ok := pass.TypesInfo.Selections[selector]
+	if !ok || selection.Kind() != types.MethodVal {
+		return "", ""
+	}
+	return gonionType(selection.Recv()), selector.Sel.Name
+}
+
+//gonionType returns the name of the gonion type, dereferencing pointers,
+//or an empty string when it isn't one of gonion's types.
+func gonionType(typ types.Type) string {
+	if pointer, ok := typ.(*types.Pointer); ok {
+		typ = pointer.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != gonionPath {
+		return ""
+	}
+	return named.Obj().Name()
+}
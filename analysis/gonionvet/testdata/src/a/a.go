@@ -0,0 +1,36 @@
+package a
+
+import (
+	"net/http"
+
+	"github.com/CoreyKaylor/gonion"
+)
+
+var handler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})
+
+func noop(rw http.ResponseWriter, r *http.Request) {}
+
+func compose() {
+	g := gonion.New()
+	g.Use().Func(noop)
+	g.Use()                  // want `middleware options are discarded`
+	g.Use().Named("logging") // want `middleware options are discarded`
+	g.Only().Get()           // want `route constraint is discarded`
+	g.Only().Get().Use().Func(noop)
+	g.Only().Use().Func(noop) // want `Only\(\) has no constraint before Use`
+	g.Only().Skip("auth")     // want `Only\(\) has no constraint before Skip`
+	g.Get("/", handler).Meta("summary", "index")
+	g.Get("index", handler) // want `Get pattern "index" should start with /`
+	g.Handle("GET", "/users", handler)
+	g.Handle("GET", "users", handler)         // want `Handle pattern "users" should start with /`
+	g.Sub("api", func(api *gonion.Composer) { // want `Sub pattern "api" should start with /`
+		api.Use().Func(noop)
+		g.Use().Func(noop) // want `g.Use is called on the outer composer inside Sub`
+		api.Sub("/admin", func(admin *gonion.Composer) {
+			admin.Get("/", handler)
+			api.Post("/", handler) // want `api.Post is called on the outer composer inside Sub`
+		})
+		local := api
+		local.Get("/local", handler)
+	})
+}
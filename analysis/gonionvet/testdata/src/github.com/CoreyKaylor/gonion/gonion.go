@@ -0,0 +1,31 @@
+package gonion
+
+import "net/http"
+
+type Composer struct{}
+
+type RouteConstraint struct{}
+
+type MiddlewareOptions struct{}
+
+type RouteOptions struct{}
+
+type RouteModel struct{}
+
+func New() *Composer { return &Composer{} }
+
+func (c *Composer) Sub(pattern string, sub func(*Composer))                       {}
+func (c *Composer) Use() *MiddlewareOptions                                       { return nil }
+func (c *Composer) Only() *RouteConstraint                                        { return nil }
+func (c *Composer) Skip(name string)                                              {}
+func (c *Composer) Get(pattern string, handler http.Handler) *RouteOptions        { return nil }
+func (c *Composer) Post(pattern string, handler http.Handler) *RouteOptions       { return nil }
+func (c *Composer) Handle(m string, p string, handler http.Handler) *RouteOptions { return nil }
+
+func (rc *RouteConstraint) Get() *RouteConstraint                           { return rc }
+func (rc *RouteConstraint) When(condition func() bool) *RouteConstraint     { return rc }
+func (rc *RouteConstraint) Use() *MiddlewareOptions                         { return nil }
+func (rc *RouteConstraint) Skip(name string)                                {}
+func (mo *MiddlewareOptions) Named(name string) *MiddlewareOptions          { return mo }
+func (mo *MiddlewareOptions) Func(func(http.ResponseWriter, *http.Request)) {}
+func (ro *RouteOptions) Meta(key string, value interface{}) *RouteOptions   { return ro }
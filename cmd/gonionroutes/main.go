@@ -0,0 +1,23 @@
+//Command gonionroutes generates typed URL helpers and an HTTP client for the
+//routes a package registers with gonion. It's intended for go generate:
+//
+//	//go:generate gonionroutes -o routes_gen.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/CoreyKaylor/gonion/routegen"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory of the package registering the routes")
+	output := flag.String("o", "routes_gen.go", "file to write the generated code to")
+	flag.Parse()
+	if err := routegen.Dir(*dir, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,12 @@
+//Command gonionvet reports common mistakes when composing routes and middleware
+//with gonion. It can be run on its own or with go vet -vettool=$(which gonionvet).
+package main
+
+import (
+	"github.com/CoreyKaylor/gonion/analysis/gonionvet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(gonionvet.Analyzer)
+}
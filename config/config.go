@@ -0,0 +1,170 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/CoreyKaylor/gonion"
+	"gopkg.in/yaml.v3"
+)
+
+//Config describes middleware and routes by the names they were registered with
+//on the Composer. Subs are nested path prefixes, the same as Composer.Sub.
+type Config struct {
+	Middleware []Middleware `json:"middleware" yaml:"middleware" toml:"middleware"`
+	Routes     []Route      `json:"routes" yaml:"routes" toml:"routes"`
+	Subs       []Sub        `json:"sub" yaml:"sub" toml:"sub"`
+}
+
+//Sub is the middleware and routes that only apply for the path prefix
+type Sub struct {
+	Prefix     string       `json:"prefix" yaml:"prefix" toml:"prefix"`
+	Middleware []Middleware `json:"middleware" yaml:"middleware" toml:"middleware"`
+	Routes     []Route      `json:"routes" yaml:"routes" toml:"routes"`
+	Subs       []Sub        `json:"sub" yaml:"sub" toml:"sub"`
+}
+
+//Middleware uses the registered middleware with the name, constrained to the
+//methods when any are given. When Enabled is false the named middleware is
+//instead skipped, whether it was used in configuration or code.
+type Middleware struct {
+	Name    string   `json:"name" yaml:"name" toml:"name"`
+	Methods []string `json:"methods" yaml:"methods" toml:"methods"`
+	Enabled *bool    `json:"enabled" yaml:"enabled" toml:"enabled"`
+}
+
+//Route adds a route using the registered handler with the name
+type Route struct {
+	Method  string `json:"method" yaml:"method" toml:"method"`
+	Pattern string `json:"pattern" yaml:"pattern" toml:"pattern"`
+	Handler string `json:"handler" yaml:"handler" toml:"handler"`
+}
+
+//Load reads the configuration from a file, using its extension to choose
+//between JSON, YAML and TOML.
+func Load(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config, err := Parse(data, strings.TrimPrefix(filepath.Ext(filename), "."))
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %v", filename, err)
+	}
+	return config, nil
+}
+
+//Parse reads the configuration in the format, which is one of json, yaml, yml or toml
+func Parse(data []byte, format string) (*Config, error) {
+	config := &Config{}
+	var err error
+	switch strings.ToLower(format) {
+	case "json":
+		err = json.Unmarshal(data, config)
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, config)
+	case "toml":
+		err = toml.Unmarshal(data, config)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+var methods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+	"DELETE": true, "CONNECT": true, "OPTIONS": true, "TRACE": true,
+}
+
+//Apply registers the configured middleware and routes on the composer. Mistakes in
+//the configuration itself are returned here, while names that aren't registered
+//on the composer are reported by Build.
+func (config *Config) Apply(composer *gonion.Composer) error {
+	if errs := validate("", config.Middleware, config.Routes, config.Subs); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	apply(composer, config.Middleware, config.Routes, config.Subs)
+	return nil
+}
+
+func validate(prefix string, middleware []Middleware, routes []Route, subs []Sub) []error {
+	var errs []error
+	at := func(format string, args ...interface{}) {
+		where := "root"
+		if prefix != "" {
+			where = "sub " + prefix
+		}
+		errs = append(errs, fmt.Errorf("config: %s: %s", where, fmt.Sprintf(format, args...)))
+	}
+	for i, m := range middleware {
+		if m.Name == "" {
+			at("middleware %d has no name", i+1)
+		}
+		for _, method := range m.Methods {
+			if !methods[strings.ToUpper(method)] {
+				at("middleware %q has unknown method %q", m.Name, method)
+			}
+		}
+	}
+	for i, route := range routes {
+		switch {
+		case !methods[strings.ToUpper(route.Method)]:
+			at("route %d has unknown method %q", i+1, route.Method)
+		case !strings.HasPrefix(route.Pattern, "/"):
+			at("route %s %q must start with /", route.Method, route.Pattern)
+		case route.Handler == "":
+			at("route %s %s has no handler", route.Method, route.Pattern)
+		}
+	}
+	for _, sub := range subs {
+		if !strings.HasPrefix(sub.Prefix, "/") {
+			at("sub prefix %q must start with /", sub.Prefix)
+			continue
+		}
+		errs = append(errs, validate(prefix+sub.Prefix, sub.Middleware, sub.Routes, sub.Subs)...)
+	}
+	return errs
+}
+
+func apply(composer *gonion.Composer, middleware []Middleware, routes []Route, subs []Sub) {
+	for _, m := range middleware {
+		enabled := m.Enabled == nil || *m.Enabled
+		if len(m.Methods) == 0 {
+			if enabled {
+				composer.Use().Registered(m.Name)
+			} else {
+				composer.Skip(m.Name)
+			}
+			continue
+		}
+		allowed := make(map[string]bool)
+		for _, method := range m.Methods {
+			allowed[strings.ToUpper(method)] = true
+		}
+		constraint := composer.Only().WhenRouteMatches(func(route *gonion.RouteModel) bool {
+			return allowed[route.Method]
+		})
+		if enabled {
+			constraint.Use().Registered(m.Name)
+		} else {
+			constraint.Skip(m.Name)
+		}
+	}
+	for _, route := range routes {
+		composer.HandleRegistered(strings.ToUpper(route.Method), route.Pattern, route.Handler)
+	}
+	for _, sub := range subs {
+		sub := sub
+		composer.Sub(sub.Prefix, func(subComposer *gonion.Composer) {
+			apply(subComposer, sub.Middleware, sub.Routes, sub.Subs)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlConfig = `
+middleware:
+  - name: recovery
+routes:
+  - method: get
+    pattern: /health
+    handler: ok
+sub:
+  - prefix: /api
+    middleware:
+      - name: ratelimit
+        methods: [POST]
+    routes:
+      - { method: GET, pattern: /users, handler: ok }
+      - { method: POST, pattern: /users, handler: ok }
+    sub:
+      - prefix: /internal
+        middleware:
+          - name: maintenance
+            enabled: false
+        routes:
+          - { method: GET, pattern: /stats, handler: ok }
+`
+
+const tomlConfig = `
+[[middleware]]
+name = "recovery"
+
+[[sub]]
+prefix = "/api"
+
+  [[sub.routes]]
+  method = "GET"
+  pattern = "/users"
+  handler = "ok"
+`
+
+func writes(text string) func(http.Handler) http.Handler {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Write([]byte(text))
+			inner.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func newComposer() *gonion.Composer {
+	g := gonion.New()
+	g.Register("recovery", writes("recovery->"))
+	g.Register("ratelimit", writes("ratelimit->"))
+	g.Register("maintenance", writes("maintenance->"))
+	g.RegisterHandler("ok", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("ok"))
+	}))
+	g.Use().Registered("maintenance")
+	return g
+}
+
+func serve(t *testing.T, routes gonion.Routes, method string, pattern string) string {
+	for _, route := range routes {
+		if route.Method == method && route.Pattern == pattern {
+			recorder := httptest.NewRecorder()
+			route.Handler.ServeHTTP(recorder, new(http.Request))
+			return recorder.Body.String()
+		}
+	}
+	t.Errorf("no route %s %s", method, pattern)
+	return ""
+}
+
+func TestApply_YAML(t *testing.T) {
+	config, err := Parse([]byte(yamlConfig), "yaml")
+	assert.Nil(t, err)
+	g := newComposer()
+	assert.Nil(t, config.Apply(g))
+	routes, err := g.Build()
+	assert.Nil(t, err)
+	assert.Equal(t, serve(t, routes, "GET", "/health"), "maintenance->recovery->ok")
+	assert.Equal(t, serve(t, routes, "GET", "/api/users"), "maintenance->recovery->ok")
+	assert.Equal(t, serve(t, routes, "POST", "/api/users"), "maintenance->recovery->ratelimit->ok")
+	assert.Equal(t, serve(t, routes, "GET", "/api/internal/stats"), "recovery->ok")
+}
+
+func TestApply_TOML(t *testing.T) {
+	config, err := Parse([]byte(tomlConfig), "toml")
+	assert.Nil(t, err)
+	g := newComposer()
+	assert.Nil(t, config.Apply(g))
+	routes, err := g.Build()
+	assert.Nil(t, err)
+	assert.Equal(t, serve(t, routes, "GET", "/api/users"), "maintenance->recovery->ok")
+}
+
+func TestApply_ReportsInvalidConfiguration(t *testing.T) {
+	config, err := Parse([]byte(`{"sub":[{"prefix":"api","routes":[]},{"prefix":"/v1","middleware":[{"name":"auth","methods":["FETCH"]}],
+		"routes":[{"method":"GET","pattern":"users","handler":"ok"}]}]}`), "json")
+	assert.Nil(t, err)
+	err = config.Apply(newComposer())
+	assert.Equal(t, err.Error(), `config: root: sub prefix "api" must start with /
+config: sub /v1: middleware "auth" has unknown method "FETCH"
+config: sub /v1: route GET "users" must start with /`)
+}
+
+func TestApply_UnregisteredNamesFailTheBuild(t *testing.T) {
+	config, err := Parse([]byte(`{"middleware":[{"name":"auth"}],"routes":[{"method":"GET","pattern":"/","handler":"index"}]}`), "json")
+	assert.Nil(t, err)
+	g := newComposer()
+	assert.Nil(t, config.Apply(g))
+	_, err = g.Build()
+	assert.Equal(t, err.Error(), `gonion: middleware "auth" is not registered
+gonion: route GET / uses handler "index" which is not registered`)
+}
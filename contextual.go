@@ -0,0 +1,51 @@
+package gonion
+
+import (
+	"fmt"
+
+	"github.com/CoreyKaylor/gonion/handlers/contextual"
+)
+
+//Contextual is called when your middleware is a contextual chain link. It can be
+//mixed with other middleware, the context it passes on is carried by the request.
+func (mo *MiddlewareOptions) Contextual(link contextual.ChainedHandler) {
+	mo.ChainLink(contextual.Middleware(link))
+}
+
+//HandleContextual adds a route for the specified method and pattern with a contextual
+//handler, which is called with the context of the request.
+func (composer *Composer) HandleContextual(method string, pattern string, handler contextual.Handler) *RouteOptions {
+	return composer.Handle(method, pattern, contextual.HTTP(handler))
+}
+
+//Provides declares the context keys, such as a contextual.Key, that the middleware
+//sets for the rest of the chain.
+func (mo *MiddlewareOptions) Provides(keys ...fmt.Stringer) *MiddlewareOptions {
+	mo.provides = append(mo.provides, keys...)
+	return mo
+}
+
+//Requires declares the context keys, such as a contextual.Key, that the route
+//needs to be set by its middleware. Build reports the route when no middleware
+//that applies to it provides one of them.
+func (ro *RouteOptions) Requires(keys ...fmt.Stringer) *RouteOptions {
+	ro.route.requires = append(ro.route.requires, keys...)
+	return ro
+}
+
+//unprovided returns the keys the route requires that none of its middleware provides
+func unprovided(route *RouteModel, middleware []*middleware) []fmt.Stringer {
+	var missing []fmt.Stringer
+	for _, key := range route.requires {
+		provided := false
+		for _, m := range middleware {
+			for _, provides := range m.provides {
+				provided = provided || provides == key
+			}
+		}
+		if !provided {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,82 @@
+package gonion
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/CoreyKaylor/gonion/handlers/contextual"
+	"github.com/stretchr/testify/assert"
+)
+
+type contextKey string
+
+func adds(key contextKey, value string) contextual.ChainedHandler {
+	return contextual.ChainLinkFunc(func(inner contextual.Handler) contextual.Handler {
+		return contextual.HandlerFunc(func(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
+			inner.ServeHTTP(context.WithValue(ctx, key, value), rw, r)
+		})
+	})
+}
+
+func writesValues(keys ...contextKey) contextual.Handler {
+	return contextual.HandlerFunc(func(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
+		for _, key := range keys {
+			value, _ := ctx.Value(key).(string)
+			rw.Write([]byte(value + ";"))
+		}
+	})
+}
+
+func TestContextual_ThreadsOneContextThroughMixedMiddleware(t *testing.T) {
+	g := New()
+	g.Use().Contextual(adds("user", "corey"))
+	g.Use().ChainLink(func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			value, _ := r.Context().Value(contextKey("user")).(string)
+			rw.Write([]byte(value + "->"))
+			inner.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), contextKey("plain"), "yes")))
+		})
+	})
+	g.Sub("/admin", func(admin *Composer) {
+		admin.Use().Contextual(adds("role", "admin"))
+		admin.HandleContextual("GET", "/", writesValues("user", "plain", "role"))
+	})
+	g.HandleContextual("GET", "/", writesValues("user", "plain", "role"))
+	routes := g.BuildRoutes()
+	assert.Equal(t, serveRoute(routes.routeFor("GET", "/")), "corey->corey;yes;;")
+	assert.Equal(t, serveRoute(routes.routeFor("GET", "/admin/")), "corey->corey;yes;admin;")
+}
+
+func TestContextual_CanBeConstrained(t *testing.T) {
+	g := New()
+	g.Only().Post().Use().Contextual(adds("user", "corey"))
+	g.HandleContextual("GET", "/", writesValues("user"))
+	g.HandleContextual("POST", "/", writesValues("user"))
+	routes := g.BuildRoutes()
+	assert.Equal(t, serveRoute(routes.routeFor("GET", "/")), ";")
+	assert.Equal(t, serveRoute(routes.routeFor("POST", "/")), "corey;")
+}
+
+func TestContextual_ReportsRequiredKeysThatAreNotProvided(t *testing.T) {
+	user := contextual.NewKey[string]("user")
+	tenant := contextual.NewKey[string]("tenant")
+	g := New()
+	g.Use().Provides(user).Contextual(contextual.ChainLinkFunc(func(inner contextual.Handler) contextual.Handler {
+		return contextual.HandlerFunc(func(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
+			inner.ServeHTTP(user.Set(ctx, "corey"), rw, r)
+		})
+	}))
+	g.HandleContextual("GET", "/", contextual.HandlerFunc(func(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(user.MustGet(ctx)))
+	})).Requires(user)
+	routes, err := g.Build()
+	assert.Nil(t, err)
+	assert.Equal(t, serveRoute(routes.routeFor("GET", "/")), "corey")
+
+	g.Sub("/internal", func(internal *Composer) {
+		internal.Get("/", http.HandlerFunc(getIndex2)).Requires(user, tenant)
+	})
+	_, err = g.Build()
+	assert.Equal(t, err.Error(), `gonion: route GET /internal/ requires "tenant" which no middleware provides`)
+}
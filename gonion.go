@@ -11,6 +11,16 @@ type Composer struct {
 	start              string
 	routeRegistry      *routeRegistry
 	middlewareRegistry *middlewareRegistry
+	settings           *settings
+}
+
+//settings are shared by a Composer and all of its Sub composers
+type settings struct {
+	tracer      *Tracer
+	chainLinks  map[string]ChainLink
+	handlers    map[string]http.Handler
+	beforeBuild []func()
+	afterBuild  []func(Routes)
 }
 
 //New is a factory method for Composer
@@ -21,6 +31,10 @@ func New() *Composer {
 		start:              "",
 		routeRegistry:      routeRegistry,
 		middlewareRegistry: middlewareRegistry,
+		settings: &settings{
+			chainLinks: make(map[string]ChainLink),
+			handlers:   make(map[string]http.Handler),
+		},
 	}
 }
 
@@ -32,14 +46,65 @@ func (composer *Composer) Sub(pattern string, sub func(*Composer)) {
 		start:              composer.start + pattern,
 		routeRegistry:      composer.routeRegistry,
 		middlewareRegistry: composer.middlewareRegistry,
+		settings:           composer.settings,
 	}
 	sub(subComposer)
 }
 
-func (composer *Composer) addMiddleware(link ChainLink, routeFilter func(*RouteModel) bool) {
-	composer.middlewareRegistry.add(func(route *RouteModel) bool {
+//Path is the path the composer's routes and middleware are under, which is
+//empty for the composer returned by New.
+func (composer *Composer) Path() string {
+	return composer.start
+}
+
+//BeforeBuild adds a hook that's called with the routes under the composer's path
+//before they're built, such as to add routes for them. It's called every time the
+//routes are built, so the routes it adds are among the routes of the next call.
+func (composer *Composer) BeforeBuild(hook func(routes []*RouteModel)) {
+	composer.settings.beforeBuild = append(composer.settings.beforeBuild, func() {
+		var routes []*RouteModel
+		for _, route := range composer.routeRegistry.routes {
+			if strings.HasPrefix(route.Pattern, composer.start) {
+				routes = append(routes, route)
+			}
+		}
+		hook(routes)
+	})
+}
+
+//AfterBuild adds a hook that's called with the built routes under the composer's
+//path every time the routes are built, such as to generate something from them
+//once at startup rather than while serving requests.
+func (composer *Composer) AfterBuild(hook func(routes Routes)) {
+	composer.settings.afterBuild = append(composer.settings.afterBuild, func(built Routes) {
+		var routes Routes
+		for _, route := range built {
+			if strings.HasPrefix(route.Pattern, composer.start) {
+				routes = append(routes, route)
+			}
+		}
+		hook(routes)
+	})
+}
+
+//scoped limits a route filter to the routes under the composer's path
+func (composer *Composer) scoped(routeFilter func(*RouteModel) bool) routeFilter {
+	return func(route *RouteModel) bool {
 		return (composer.start == "" || strings.HasPrefix(route.Pattern, composer.start)) && routeFilter(route)
-	}, link)
+	}
+}
+
+func (composer *Composer) addMiddleware(link RouteChainLink, options *MiddlewareOptions) {
+	middleware := composer.middlewareRegistry.addForRoute(composer.scoped(options.routeFilter), link)
+	for key, value := range options.metadata {
+		middleware.metadata[key] = value
+	}
+	middleware.name = options.name
+	middleware.ref = options.ref
+	middleware.provides = options.provides
+	if middleware.name == "" {
+		middleware.name = callerOutsideGonion()
+	}
 }
 
 //Use is the entrypoint to adding middleware
@@ -57,33 +122,34 @@ func (composer *Composer) useWhen(routeFilter func(*RouteModel) bool) *Middlewar
 }
 
 //Get adds a route constrained to only 'GET' requests
-func (composer *Composer) Get(pattern string, handler http.Handler) {
-	composer.Handle("GET", pattern, handler)
+func (composer *Composer) Get(pattern string, handler http.Handler) *RouteOptions {
+	return composer.Handle("GET", pattern, handler)
 }
 
 //Post adds a route constrained to only 'POST' requests
-func (composer *Composer) Post(pattern string, handler http.Handler) {
-	composer.Handle("POST", pattern, handler)
+func (composer *Composer) Post(pattern string, handler http.Handler) *RouteOptions {
+	return composer.Handle("POST", pattern, handler)
 }
 
 //Put adds a route constrained to only 'PUT' requests
-func (composer *Composer) Put(pattern string, handler http.Handler) {
-	composer.Handle("PUT", pattern, handler)
+func (composer *Composer) Put(pattern string, handler http.Handler) *RouteOptions {
+	return composer.Handle("PUT", pattern, handler)
 }
 
 //Patch adds a route constrained to only 'PATCH' requests
-func (composer *Composer) Patch(pattern string, handler http.Handler) {
-	composer.Handle("PATCH", pattern, handler)
+func (composer *Composer) Patch(pattern string, handler http.Handler) *RouteOptions {
+	return composer.Handle("PATCH", pattern, handler)
 }
 
 //Delete adds a route constrained to only 'DELETE' requests
-func (composer *Composer) Delete(pattern string, handler http.Handler) {
-	composer.Handle("DELETE", pattern, handler)
+func (composer *Composer) Delete(pattern string, handler http.Handler) *RouteOptions {
+	return composer.Handle("DELETE", pattern, handler)
 }
 
 //Handle adds a route for the specified method and pattern
-func (composer *Composer) Handle(method string, pattern string, handler http.Handler) {
-	composer.routeRegistry.addRoute(method, composer.start+pattern, handler)
+func (composer *Composer) Handle(method string, pattern string, handler http.Handler) *RouteOptions {
+	route := composer.routeRegistry.addRoute(method, composer.start+pattern, handler)
+	return &RouteOptions{route: route}
 }
 
 //RouteConstraint is how middleware is constrained after calling Only()
@@ -157,31 +223,69 @@ func (rc *RouteConstraint) Use() *MiddlewareOptions {
 type Routes []*Route
 
 //Route is the handler and route information after calling BuildRoutes. Handler
-//is the entire chain of route handler and middleware.
+//is the entire chain of route handler and middleware. Metadata is the route's own
+//metadata combined with the metadata of the middleware that applies to it.
 type Route struct {
-	Method  string
-	Pattern string
-	Handler http.Handler
+	Method   string
+	Pattern  string
+	Handler  http.Handler
+	Metadata Metadata
+	Produces []string
 }
 
 //BuildRoutes returns routes with their corresponding handler chain.
 //This is typically what you will call before delegating to the router
-//you have chosen for your application.
+//you have chosen for your application. Routes sharing a method and pattern
+//that declare the media types they produce are combined into a single route.
+//BuildRoutes panics when Build returns an error.
 func (composer *Composer) BuildRoutes() Routes {
+	routes, err := composer.Build()
+	if err != nil {
+		panic(err)
+	}
+	return routes
+}
+
+//Build is like BuildRoutes, but returns an error when the composition is invalid,
+//such as referring to a handler or middleware name that was never registered.
+func (composer *Composer) Build() (Routes, error) {
+	for _, hook := range composer.settings.beforeBuild {
+		hook()
+	}
+	if err := composer.validate(); err != nil {
+		return nil, err
+	}
+	variants := make(map[string][]*RouteModel)
+	for _, route := range composer.routeRegistry.routes {
+		if len(route.Produces) > 0 {
+			key := route.Method + " " + route.Pattern
+			variants[key] = append(variants[key], route)
+		}
+	}
 	routes := make(Routes, 0, 10)
 	for i := len(composer.routeRegistry.routes) - 1; i >= 0; i-- {
 		route := composer.routeRegistry.routes[i]
-		middleware := composer.middlewareRegistry.middlewareFor(route)
-
-		handler := build(route.Handler, middleware)
-		builtRoute := &Route{
-			Method:  route.Method,
-			Pattern: route.Pattern,
-			Handler: handler,
+		if len(route.Produces) > 0 {
+			key := route.Method + " " + route.Pattern
+			if variants[key] == nil {
+				continue
+			}
+			route = combine(variants[key])
+			delete(variants, key)
 		}
-		routes = append(routes, builtRoute)
+		middleware := composer.middlewareRegistry.middlewareFor(route)
+		routes = append(routes, &Route{
+			Method:   route.Method,
+			Pattern:  route.Pattern,
+			Handler:  build(route, middleware, composer.settings.tracer),
+			Metadata: metadataFor(route, middleware),
+			Produces: route.Produces,
+		})
 	}
-	return routes
+	for _, hook := range composer.settings.afterBuild {
+		hook(routes)
+	}
+	return routes, nil
 }
 
 //EachRoute is a convenience method for BuildRoutes that you can
@@ -142,3 +142,72 @@ func (routes Routes) routeFor(method string, pattern string) *Route {
 func getIndex2(rw http.ResponseWriter, r *http.Request) {
 	rw.Write([]byte("Success!"))
 }
+
+func TestRouteMetadata_CombinesMiddlewareAndRouteMetadata(t *testing.T) {
+	g := New()
+	g.Sub("/api", func(api *Composer) {
+		api.Use().Meta("auth", "apiKey").Meta("summary", "api").Func(func(rw http.ResponseWriter, r *http.Request) {})
+		api.Get("/users", http.HandlerFunc(getIndex2)).Meta("summary", "List users")
+	})
+	g.Get("/", http.HandlerFunc(getIndex2))
+	routes := g.BuildRoutes()
+	users := routes.routeFor("GET", "/api/users")
+	assert.Equal(t, users.Metadata, Metadata{"auth": "apiKey", "summary": "List users"})
+	assert.Equal(t, routes.routeFor("GET", "/").Metadata, Metadata{})
+}
+
+func TestRouteChainLink_IsGivenTheRouteItWraps(t *testing.T) {
+	g := oneOfEachRoute()
+	g.Use().RouteChainLink(func(route *RouteModel) ChainLink {
+		return func(inner http.Handler) http.Handler {
+			return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Write([]byte(route.Method + "->"))
+				inner.ServeHTTP(rw, r)
+			})
+		}
+	})
+	assertRouteConstraintResponse(t, g, "GET", "GET->GET")
+	assertRouteConstraintResponse(t, g, "DELETE", "DELETE->DELETE")
+}
+
+func TestBeforeBuild_CanAddRoutesUnderThePath(t *testing.T) {
+	g := New()
+	g.Get("/", http.HandlerFunc(getIndex2))
+	g.Sub("/api", func(api *Composer) {
+		assert.Equal(t, api.Path(), "/api")
+		api.BeforeBuild(func(routes []*RouteModel) {
+			heads := make(map[string]bool)
+			for _, route := range routes {
+				if route.Method == "HEAD" {
+					heads[route.Pattern] = true
+				}
+			}
+			for _, route := range routes {
+				if route.Method == "GET" && !heads[route.Pattern] {
+					api.Handle("HEAD", route.Pattern[len(api.Path()):], route.Handler)
+				}
+			}
+		})
+		api.Get("/users", http.HandlerFunc(getIndex2))
+	})
+	routes := g.BuildRoutes()
+	assert.Equal(t, len(routes), 3)
+	assert.NotNil(t, routes.routeFor("HEAD", "/api/users"))
+	assert.Nil(t, routes.routeFor("HEAD", "/"))
+	assert.Equal(t, len(g.BuildRoutes()), 3)
+}
+
+func TestAfterBuild_IsCalledWithTheBuiltRoutesUnderThePath(t *testing.T) {
+	g := New()
+	g.Get("/", http.HandlerFunc(getIndex2))
+	var built Routes
+	g.Sub("/api", func(api *Composer) {
+		api.AfterBuild(func(routes Routes) {
+			built = routes
+		})
+		api.Get("/users", http.HandlerFunc(getIndex2))
+	})
+	routes := g.BuildRoutes()
+	assert.Equal(t, len(built), 1)
+	assert.Equal(t, built[0], routes.routeFor("GET", "/api/users"))
+}
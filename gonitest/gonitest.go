@@ -0,0 +1,245 @@
+package gonitest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CoreyKaylor/gonion"
+)
+
+//Kit composes routes for a test and dispatches requests through their built
+//handler chains the same way a router would.
+type Kit struct {
+	t        testing.TB
+	composer *gonion.Composer
+	routes   gonion.Routes
+	mutex    sync.Mutex
+	trail    []string
+}
+
+//New is a factory method for Kit with a new Composer to register the routes
+//and middleware under test on.
+func New(t testing.TB) *Kit {
+	return &Kit{
+		t:        t,
+		composer: gonion.New(),
+	}
+}
+
+//Composer returns the composer to register the routes and middleware on
+func (k *Kit) Composer() *gonion.Composer {
+	return k.composer
+}
+
+//Routes returns the built routes, building them on first use. Routes registered
+//after the first request are not included.
+func (k *Kit) Routes() gonion.Routes {
+	if k.routes == nil {
+		k.routes = k.composer.BuildRoutes()
+	}
+	return k.routes
+}
+
+//Route returns the route matching the method and path along with the values of
+//its parameters. Static segments are preferred over parameters when more than one
+//route matches, nil is returned when no route does.
+func (k *Kit) Route(method string, path string) (*gonion.Route, map[string]string) {
+	var match *gonion.Route
+	var matchParams map[string]string
+	for _, route := range k.Routes() {
+		if route.Method != method {
+			continue
+		}
+		params, ok := gonion.MatchPattern(route.Pattern, path)
+		if ok && (match == nil || len(params) < len(matchParams)) {
+			match, matchParams = route, params
+		}
+	}
+	return match, matchParams
+}
+
+//Track wraps a ChainLink so that its name is recorded in the trail every time it
+//runs, which is what AssertRan checks.
+func (k *Kit) Track(name string, link gonion.ChainLink) gonion.ChainLink {
+	return func(inner http.Handler) http.Handler {
+		wrapped := link(inner)
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			k.mutex.Lock()
+			k.trail = append(k.trail, name)
+			k.mutex.Unlock()
+			wrapped.ServeHTTP(rw, r)
+		})
+	}
+}
+
+//Mark is a ChainLink that does nothing except record its name in the trail
+func (k *Kit) Mark(name string) gonion.ChainLink {
+	return k.Track(name, func(inner http.Handler) http.Handler {
+		return inner
+	})
+}
+
+//Trail returns the names of the tracked middleware that ran during the last request
+func (k *Kit) Trail() []string {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return append([]string(nil), k.trail...)
+}
+
+//AssertRan asserts that exactly the named middleware ran during the last request,
+//in the order given.
+func (k *Kit) AssertRan(names ...string) {
+	k.t.Helper()
+	trail := k.Trail()
+	if strings.Join(trail, ",") != strings.Join(names, ",") {
+		k.t.Errorf("expected middleware %v to run, but %v ran", names, trail)
+	}
+}
+
+//Do dispatches a request to the route matching its method and path. Requests that
+//don't match a route fail the test and get a 404.
+func (k *Kit) Do(r *http.Request) *Response {
+	k.t.Helper()
+	k.mutex.Lock()
+	k.trail = nil
+	k.mutex.Unlock()
+	recorder := httptest.NewRecorder()
+	route, _ := k.Route(r.Method, r.URL.Path)
+	if route == nil {
+		k.t.Errorf("no route matches %s %s", r.Method, r.URL.Path)
+		http.NotFound(recorder, r)
+	} else {
+		route.Handler.ServeHTTP(recorder, r)
+	}
+	return &Response{t: k.t, ResponseRecorder: recorder}
+}
+
+//Request is a convenience method for Do that builds the request
+func (k *Kit) Request(method string, path string, body io.Reader) *Response {
+	k.t.Helper()
+	return k.Do(httptest.NewRequest(method, path, body))
+}
+
+//Get is a convenience method for a 'GET' request without a body
+func (k *Kit) Get(path string) *Response {
+	k.t.Helper()
+	return k.Request("GET", path, nil)
+}
+
+//Post is a convenience method for a 'POST' request
+func (k *Kit) Post(path string, body io.Reader) *Response {
+	k.t.Helper()
+	return k.Request("POST", path, body)
+}
+
+//Put is a convenience method for a 'PUT' request
+func (k *Kit) Put(path string, body io.Reader) *Response {
+	k.t.Helper()
+	return k.Request("PUT", path, body)
+}
+
+//Patch is a convenience method for a 'PATCH' request
+func (k *Kit) Patch(path string, body io.Reader) *Response {
+	k.t.Helper()
+	return k.Request("PATCH", path, body)
+}
+
+//Delete is a convenience method for a 'DELETE' request without a body
+func (k *Kit) Delete(path string) *Response {
+	k.t.Helper()
+	return k.Request("DELETE", path, nil)
+}
+
+//RouteTable is a snapshot of the built routes, one "METHOD PATTERN" line per route
+//sorted by pattern and then method. Media types are listed for negotiated routes.
+func RouteTable(routes gonion.Routes) string {
+	lines := make([]string, 0, len(routes))
+	for _, route := range routes {
+		line := route.Method + " " + route.Pattern
+		if len(route.Produces) > 0 {
+			line += " " + strings.Join(route.Produces, ",")
+		}
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		pi, mi := patternAndMethod(lines[i])
+		pj, mj := patternAndMethod(lines[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return mi < mj
+	})
+	return strings.Join(lines, "\n")
+}
+
+func patternAndMethod(line string) (string, string) {
+	fields := strings.Fields(line)
+	return fields[1], fields[0]
+}
+
+//AssertRouteTable asserts the snapshot of the built routes matches, ignoring
+//indentation and blank lines so that it can be written as a raw string.
+func (k *Kit) AssertRouteTable(expected string) {
+	k.t.Helper()
+	actual := RouteTable(k.Routes())
+	if normalize(expected) != actual {
+		k.t.Errorf("route table doesn't match\nexpected:\n%s\nactual:\n%s", normalize(expected), actual)
+	}
+}
+
+func normalize(table string) string {
+	lines := make([]string, 0, 10)
+	for _, line := range strings.Split(table, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+//Response is the recorded response of a request with assertions that can be chained
+type Response struct {
+	*httptest.ResponseRecorder
+	t testing.TB
+}
+
+//AssertStatus asserts the status code of the response
+func (r *Response) AssertStatus(status int) *Response {
+	r.t.Helper()
+	if r.Code != status {
+		r.t.Errorf("expected status %d, but was %d", status, r.Code)
+	}
+	return r
+}
+
+//AssertHeader asserts the value of a response header
+func (r *Response) AssertHeader(name string, value string) *Response {
+	r.t.Helper()
+	if actual := r.Header().Get(name); actual != value {
+		r.t.Errorf("expected header %s to be %q, but was %q", name, value, actual)
+	}
+	return r
+}
+
+//AssertBody asserts the entire body of the response
+func (r *Response) AssertBody(body string) *Response {
+	r.t.Helper()
+	if actual := r.Body.String(); actual != body {
+		r.t.Errorf("expected body %q, but was %q", body, actual)
+	}
+	return r
+}
+
+//AssertBodyContains asserts the body of the response contains the text
+func (r *Response) AssertBodyContains(text string) *Response {
+	r.t.Helper()
+	if actual := r.Body.String(); !strings.Contains(actual, text) {
+		r.t.Errorf("expected body to contain %q, but was %q", text, actual)
+	}
+	return r
+}
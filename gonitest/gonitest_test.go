@@ -0,0 +1,81 @@
+package gonitest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingT struct {
+	*testing.T
+	failures []string
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func composeAPI(t testing.TB) *Kit {
+	k := New(t)
+	g := k.Composer()
+	g.Use().ChainLink(k.Mark("logging"))
+	g.Get("/", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("index"))
+	}))
+	g.Sub("/api", func(api *gonion.Composer) {
+		api.Use().ChainLink(k.Mark("auth"))
+		api.Get("/users/:id", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "text/plain")
+			rw.Write([]byte("user"))
+		}))
+		api.Get("/users/me", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Write([]byte("me"))
+		}))
+		api.Post("/users", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusCreated)
+		}))
+	})
+	return k
+}
+
+func TestKit_DispatchesThroughTheChain(t *testing.T) {
+	k := composeAPI(t)
+	k.Get("/api/users/5").
+		AssertStatus(http.StatusOK).
+		AssertHeader("Content-Type", "text/plain").
+		AssertBody("user")
+	k.AssertRan("logging", "auth")
+
+	k.Get("/api/users/me").AssertBody("me")
+	k.Post("/api/users", strings.NewReader("{}")).AssertStatus(http.StatusCreated)
+	k.Get("/").AssertBodyContains("ind")
+	k.AssertRan("logging")
+}
+
+func TestKit_RouteTable(t *testing.T) {
+	k := composeAPI(t)
+	k.AssertRouteTable(`
+		GET /
+		POST /api/users
+		GET /api/users/:id
+		GET /api/users/me
+	`)
+}
+
+func TestKit_ReportsFailures(t *testing.T) {
+	recorder := &recordingT{T: t}
+	k := composeAPI(recorder)
+	k.Get("/missing").AssertStatus(http.StatusNotFound)
+	k.Get("/").AssertStatus(http.StatusTeapot).AssertBody("nope")
+	k.AssertRan("auth")
+	assert.Equal(t, recorder.failures, []string{
+		"no route matches GET /missing",
+		"expected status 418, but was 200",
+		`expected body "nope", but was "index"`,
+		"expected middleware [auth] to run, but [logging] ran",
+	})
+}
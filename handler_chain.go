@@ -1,6 +1,7 @@
 package gonion
 
 import (
+	"fmt"
 	"net/http"
 )
 
@@ -8,10 +9,17 @@ import (
 //of the handler chain.
 type ChainLink func(http.Handler) http.Handler
 
-func build(handler http.Handler, middleware []*middleware) http.Handler {
-	chain := handler
+//RouteChainLink is used when the ChainLink needs to know which route it
+//is wrapping. It's called once per route while building the routes.
+type RouteChainLink func(*RouteModel) ChainLink
+
+func build(route *RouteModel, middleware []*middleware, tracer *Tracer) http.Handler {
+	if tracer != nil {
+		return tracer.build(route, middleware)
+	}
+	chain := route.Handler
 	for i := len(middleware) - 1; i >= 0; i-- {
-		chain = middleware[i].handler(chain)
+		chain = middleware[i].handler(route)(chain)
 	}
 	return chain
 }
@@ -23,18 +31,24 @@ type routeRegistry struct {
 //RouteModel is the pre-build model representing a single handler
 //without middleware.
 type RouteModel struct {
-	Method  string
-	Pattern string
-	Handler http.Handler
+	Method   string
+	Pattern  string
+	Handler  http.Handler
+	Metadata Metadata
+	Produces []string
+	ref      string
+	requires []fmt.Stringer
 }
 
-func (r *routeRegistry) addRoute(method string, pattern string, handler http.Handler) {
+func (r *routeRegistry) addRoute(method string, pattern string, handler http.Handler) *RouteModel {
 	route := &RouteModel{
-		Method:  method,
-		Pattern: pattern,
-		Handler: handler,
+		Method:   method,
+		Pattern:  pattern,
+		Handler:  handler,
+		Metadata: Metadata{},
 	}
 	r.routes = append(r.routes, route)
+	return route
 }
 
 func newRouteRegistry() *routeRegistry {
@@ -45,11 +59,21 @@ func newRouteRegistry() *routeRegistry {
 
 type middlewareRegistry struct {
 	middleware []*middleware
+	skips      []*skip
+}
+
+type skip struct {
+	filter routeFilter
+	name   string
 }
 
 type middleware struct {
-	filter  routeFilter
-	handler ChainLink
+	filter   routeFilter
+	handler  RouteChainLink
+	metadata Metadata
+	name     string
+	ref      string
+	provides []fmt.Stringer
 }
 
 type routeFilter func(*RouteModel) bool
@@ -66,20 +90,37 @@ func (m *middlewareRegistry) appliesToAllRoutes(handler ChainLink) {
 	}, handler)
 }
 
-func (m *middlewareRegistry) add(filter routeFilter, handler ChainLink) {
+func (m *middlewareRegistry) add(filter routeFilter, handler ChainLink) *middleware {
+	return m.addForRoute(filter, func(*RouteModel) ChainLink {
+		return handler
+	})
+}
+
+func (m *middlewareRegistry) addForRoute(filter routeFilter, handler RouteChainLink) *middleware {
 	middleware := &middleware{
-		filter:  filter,
-		handler: handler,
+		filter:   filter,
+		handler:  handler,
+		metadata: Metadata{},
 	}
 	m.middleware = append(m.middleware, middleware)
+	return middleware
 }
 
 func (m *middlewareRegistry) middlewareFor(route *RouteModel) []*middleware {
 	ret := make([]*middleware, 0, 10)
 	for _, middle := range m.middleware {
-		if middle.filter(route) {
+		if middle.filter(route) && !m.skipped(middle, route) {
 			ret = append(ret, middle)
 		}
 	}
 	return ret
 }
+
+func (m *middlewareRegistry) skipped(middle *middleware, route *RouteModel) bool {
+	for _, skip := range m.skips {
+		if skip.name == middle.name && skip.filter(route) {
+			return true
+		}
+	}
+	return false
+}
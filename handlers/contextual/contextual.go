@@ -1,9 +1,9 @@
 package contextual
 
 import (
+	"context"
+	"fmt"
 	"net/http"
-
-	"code.google.com/p/go.net/context"
 )
 
 //Handler is similar to http.Handler but with the added context.Context parameter
@@ -34,17 +34,94 @@ func (chain ChainLinkFunc) ChainLink(inner Handler) Handler {
 	return chain(inner)
 }
 
+//NotFound is a terminal handler that responds with 404 Not Found
+var NotFound Handler = HandlerFunc(func(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
+	http.NotFound(rw, r)
+})
+
+//NoOp is a terminal handler that does nothing
+var NoOp Handler = HandlerFunc(func(context.Context, http.ResponseWriter, *http.Request) {})
+
 //Handlers is a variadic function that will describe the order of contextual handlers
-//and returns a standard http.Handler to be used for the request.
+//and returns a standard http.Handler to be used for the request. The chain starts
+//with the request's context, so it's canceled along with the request. When the
+//last handler calls the next handler, the response is 404 Not Found.
 func Handlers(handlers ...ChainedHandler) http.Handler {
-	contextChain := buildChain(handlers...)
+	return Terminated(NotFound, handlers...)
+}
+
+//Terminated is like Handlers, but the terminal handler is called when the last
+//handler calls the next handler, such as NoOp or a fallback made with FromHTTP.
+//It panics when the terminal or one of the handlers is nil.
+func Terminated(terminal Handler, handlers ...ChainedHandler) http.Handler {
+	return HTTP(buildChain(terminal, handlers...))
+}
+
+//HTTP adapts a contextual handler to a standard http.Handler that's called with
+//the request's context.
+func HTTP(handler Handler) http.Handler {
+	if isNil(handler) {
+		panic("contextual: handler is nil")
+	}
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		contextChain.ServeHTTP(context.Background(), rw, r)
+		handler.ServeHTTP(r.Context(), rw, r)
+	})
+}
+
+//FromHTTP adapts a standard http.Handler to a contextual handler. The handler is
+//called with a request carrying the context.
+func FromHTTP(handler http.Handler) Handler {
+	if handler == nil {
+		panic("contextual: http.Handler is nil")
+	}
+	return withRequestContext(HandlerFunc(func(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(rw, r)
+	}))
+}
+
+//Middleware adapts a contextual chain link to standard middleware, so it can be
+//mixed with middleware that isn't contextual. The context passed to the rest of
+//the chain is carried by the request.
+func Middleware(link ChainedHandler) func(http.Handler) http.Handler {
+	if isNil(link) {
+		panic("contextual: chained handler is nil")
+	}
+	return func(next http.Handler) http.Handler {
+		chain := link.ChainLink(FromHTTP(next))
+		if isNil(chain) {
+			panic("contextual: chained handler returned a nil handler")
+		}
+		return HTTP(chain)
+	}
+}
+
+//FromMiddleware adapts standard middleware, such as a gonion.ChainLink, to a contextual
+//chain link. The context it passes on is carried by the request.
+func FromMiddleware(ctor func(http.Handler) http.Handler) ChainedHandler {
+	if ctor == nil {
+		panic("contextual: middleware is nil")
+	}
+	return ChainLinkFunc(func(inner Handler) Handler {
+		return FromHTTP(ctor(HTTP(inner)))
+	})
+}
+
+//withRequestContext keeps the request's context the same as the context passed
+//down the chain, so that r.Context() sees values added by contextual handlers.
+func withRequestContext(inner Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
+		if r.Context() != ctx {
+			r = r.WithContext(ctx)
+		}
+		inner.ServeHTTP(ctx, rw, r)
 	})
 }
 
 //Last is intended for the last handler in the chain that will not require wrapping the next handler.
 func Last(handler Handler) ChainedHandler {
+	if isNil(handler) {
+		panic("contextual: last handler is nil")
+	}
 	return ChainLinkFunc(func(inner Handler) Handler {
 		return HandlerFunc(func(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
 			handler.ServeHTTP(ctx, rw, r)
@@ -53,11 +130,32 @@ func Last(handler Handler) ChainedHandler {
 	})
 }
 
-func buildChain(handlers ...ChainedHandler) Handler {
-	length := len(handlers)
-	var chain Handler
-	for i := length - 1; i >= 0; i-- {
-		chain = handlers[i].ChainLink(chain)
+func buildChain(terminal Handler, handlers ...ChainedHandler) Handler {
+	if isNil(terminal) {
+		panic("contextual: terminal handler is nil")
+	}
+	chain := terminal
+	for i := len(handlers) - 1; i >= 0; i-- {
+		if isNil(handlers[i]) {
+			panic(fmt.Sprintf("contextual: handler %d is nil", i))
+		}
+		chain = handlers[i].ChainLink(withRequestContext(chain))
+		if isNil(chain) {
+			panic(fmt.Sprintf("contextual: handler %d returned a nil handler", i))
+		}
 	}
 	return chain
 }
+
+//isNil also catches nil funcs, which aren't nil once they're converted to an interface
+func isNil(handler interface{}) bool {
+	switch handler := handler.(type) {
+	case nil:
+		return true
+	case HandlerFunc:
+		return handler == nil
+	case ChainLinkFunc:
+		return handler == nil
+	}
+	return false
+}
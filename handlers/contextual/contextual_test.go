@@ -1,11 +1,12 @@
 package contextual
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
-	"code.google.com/p/go.net/context"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestContextualHandlers(t *testing.T) {
@@ -20,10 +21,37 @@ func TestContextualHandlers(t *testing.T) {
 	}
 }
 
-var key = context.NewKey("test")
+func TestContextualHandlers_StartWithTheRequestContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(key.Set(context.Background(), "from request"))
+	cancel()
+	var err error
+	handler := Handlers(Last(HandlerFunc(func(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
+		err = ctx.Err()
+		ContextualTwo(ctx, rw, r)
+	})))
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, recorder.Body.String(), "from request")
+	assert.Equal(t, err, context.Canceled)
+}
+
+func TestContextualHandlers_WriteTheContextBackToTheRequest(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	handler := Handlers(
+		ChainLinkFunc(ContextualOneChainLink),
+		Last(HandlerFunc(func(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
+			rw.Write([]byte(key.MustGet(r.Context())))
+		})),
+	)
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Body.String(), "hello")
+}
+
+var key = NewKey[string]("test")
 
 func ContextualOne(ctx context.Context, rw http.ResponseWriter, r *http.Request, next Handler) {
-	next.ServeHTTP(context.WithValue(ctx, key, "hello"), rw, r)
+	next.ServeHTTP(key.Set(ctx, "hello"), rw, r)
 }
 
 func ContextualOneChainLink(inner Handler) Handler {
@@ -33,6 +61,76 @@ func ContextualOneChainLink(inner Handler) Handler {
 }
 
 func ContextualTwo(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
-	message := ctx.Value(key).(string)
-	rw.Write([]byte(message))
+	rw.Write([]byte(key.MustGet(ctx)))
+}
+
+func TestMiddleware_CarriesTheContextOnTheRequest(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	handler := Middleware(ChainLinkFunc(ContextualOneChainLink))(HTTP(HandlerFunc(ContextualTwo)))
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Body.String(), "hello")
+}
+
+func TestKey_GetsTypedValues(t *testing.T) {
+	count := NewKey[int]("count")
+	ctx := count.Set(context.Background(), 3)
+	value, ok := count.Get(ctx)
+	assert.Equal(t, value, 3)
+	assert.True(t, ok)
+	_, ok = NewKey[int]("count").Get(ctx)
+	assert.False(t, ok)
+	assert.Equal(t, count.String(), "count")
+	assert.PanicsWithValue(t, `contextual: no value for key "test"`, func() {
+		key.MustGet(ctx)
+	})
+}
+
+func TestHandlers_EndWithATerminal(t *testing.T) {
+	link := ChainLinkFunc(ContextualOneChainLink)
+	recorder := httptest.NewRecorder()
+	Handlers(link).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+
+	recorder = httptest.NewRecorder()
+	Terminated(NoOp, link).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Body.String(), "")
+
+	recorder = httptest.NewRecorder()
+	fallback := FromHTTP(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("fallback " + key.MustGet(r.Context())))
+	}))
+	Terminated(fallback, link).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Body.String(), "fallback hello")
+}
+
+func TestHandlers_PanicOnNilHandlersWhenBuilt(t *testing.T) {
+	assert.PanicsWithValue(t, "contextual: handler 1 is nil", func() {
+		Handlers(ChainLinkFunc(ContextualOneChainLink), nil)
+	})
+	assert.PanicsWithValue(t, "contextual: handler 0 is nil", func() {
+		Handlers(ChainLinkFunc(nil))
+	})
+	assert.PanicsWithValue(t, "contextual: handler 0 returned a nil handler", func() {
+		Handlers(ChainLinkFunc(func(Handler) Handler { return nil }))
+	})
+	assert.PanicsWithValue(t, "contextual: terminal handler is nil", func() {
+		Terminated(nil)
+	})
+	assert.PanicsWithValue(t, "contextual: last handler is nil", func() {
+		Last(HandlerFunc(nil))
+	})
+}
+
+func TestFromMiddleware_CarriesTheContextOnTheRequest(t *testing.T) {
+	plain := func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Write([]byte(key.MustGet(r.Context()) + "->"))
+			inner.ServeHTTP(rw, r.WithContext(key.Set(r.Context(), "world")))
+		})
+	}
+	recorder := httptest.NewRecorder()
+	handler := Handlers(ChainLinkFunc(ContextualOneChainLink), FromMiddleware(plain), Last(HandlerFunc(ContextualTwo)))
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Body.String(), "hello->world")
 }
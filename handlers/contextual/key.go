@@ -0,0 +1,43 @@
+package contextual
+
+import (
+	"context"
+	"fmt"
+)
+
+//Key is a typed key for a value shared between layers through the context,
+//so that getting the value doesn't need a type assertion.
+type Key[T any] struct {
+	name string
+}
+
+//NewKey creates a key for values of type T. The name is only used to describe
+//the key, two keys with the same name are still different keys.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+//String is the name of the key
+func (key *Key[T]) String() string {
+	return key.name
+}
+
+//Set returns a copy of the context with the value for the key
+func (key *Key[T]) Set(ctx context.Context, value T) context.Context {
+	return context.WithValue(ctx, key, value)
+}
+
+//Get returns the value for the key and whether it was present
+func (key *Key[T]) Get(ctx context.Context) (T, bool) {
+	value, ok := ctx.Value(key).(T)
+	return value, ok
+}
+
+//MustGet returns the value for the key and panics when it isn't present
+func (key *Key[T]) MustGet(ctx context.Context) T {
+	value, ok := key.Get(ctx)
+	if !ok {
+		panic(fmt.Sprintf("contextual: no value for key %q", key.name))
+	}
+	return value
+}
@@ -0,0 +1,47 @@
+package gonion
+
+//NameKey is the metadata key of a route's name given by RouteOptions.Named
+const NameKey = "gonion.name"
+
+//Metadata is additional information describing a route that can be used
+//by tooling and middleware while building the routes, such as generating
+//documentation.
+type Metadata map[string]interface{}
+
+//Get returns the value for the key and whether it was present
+func (m Metadata) Get(key string) (interface{}, bool) {
+	value, ok := m[key]
+	return value, ok
+}
+
+//RouteOptions is returned when adding a route and allows describing
+//the route further.
+type RouteOptions struct {
+	route *RouteModel
+}
+
+//Meta adds a metadata value to the route. Route metadata takes precedence
+//over metadata of the same key from middleware.
+func (ro *RouteOptions) Meta(key string, value interface{}) *RouteOptions {
+	ro.route.Metadata[key] = value
+	return ro
+}
+
+//Named gives the route a name to identify it by, such as the name of its
+//generated URL helper or its OpenAPI operation id.
+func (ro *RouteOptions) Named(name string) *RouteOptions {
+	return ro.Meta(NameKey, name)
+}
+
+func metadataFor(route *RouteModel, middleware []*middleware) Metadata {
+	metadata := Metadata{}
+	for _, m := range middleware {
+		for key, value := range m.metadata {
+			metadata[key] = value
+		}
+	}
+	for key, value := range route.Metadata {
+		metadata[key] = value
+	}
+	return metadata
+}
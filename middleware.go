@@ -1,6 +1,7 @@
 package gonion
 
 import (
+	"fmt"
 	"net/http"
 )
 
@@ -9,12 +10,42 @@ import (
 type MiddlewareOptions struct {
 	composer    *Composer
 	routeFilter func(*RouteModel) bool
+	metadata    Metadata
+	name        string
+	ref         string
+	provides    []fmt.Stringer
+}
+
+//Named gives the middleware a name to identify it by when tracing. Without a
+//name it's identified by the file and line it was registered on.
+func (mo *MiddlewareOptions) Named(name string) *MiddlewareOptions {
+	mo.name = name
+	return mo
+}
+
+//Meta describes the middleware with a metadata value that is added to
+//every route the middleware applies to.
+func (mo *MiddlewareOptions) Meta(key string, value interface{}) *MiddlewareOptions {
+	if mo.metadata == nil {
+		mo.metadata = Metadata{}
+	}
+	mo.metadata[key] = value
+	return mo
 }
 
 //ChainLink is called when your middleware handler needs to wrap the rest
 //of the handler chain.
 func (mo *MiddlewareOptions) ChainLink(ctor func(http.Handler) http.Handler) {
-	mo.composer.addMiddleware(ChainLink(ctor), mo.routeFilter)
+	mo.RouteChainLink(func(*RouteModel) ChainLink {
+		return ChainLink(ctor)
+	})
+}
+
+//RouteChainLink is called when your middleware handler needs to wrap the rest
+//of the handler chain and know which route it's wrapping, such as reporting
+//the route's pattern or reading its metadata.
+func (mo *MiddlewareOptions) RouteChainLink(ctor func(*RouteModel) ChainLink) {
+	mo.composer.addMiddleware(RouteChainLink(ctor), mo)
 }
 
 func wrap(handler http.Handler) ChainLink {
@@ -28,7 +59,7 @@ func wrap(handler http.Handler) ChainLink {
 
 //Handler is middleware that conforms to the standard http.Handler interface
 func (mo *MiddlewareOptions) Handler(handler http.Handler) {
-	mo.composer.addMiddleware(wrap(handler), mo.routeFilter)
+	mo.ChainLink(wrap(handler))
 }
 
 //Func is a convenience method for a func that matches the signature of the
@@ -0,0 +1,169 @@
+package accesslog
+
+import (
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/CoreyKaylor/gonion/writer"
+)
+
+//Entry is what's logged about a request
+type Entry struct {
+	Time       time.Time
+	RemoteAddr string
+	User       string
+	Method     string
+	URI        string
+	Proto      string
+	Route      string
+	Status     int
+	Size       int64
+	Duration   time.Duration
+	Referer    string
+	UserAgent  string
+}
+
+//Logger logs the requests of the routes it's registered for
+type Logger struct {
+	output   Output
+	sample   float64
+	excluded []string
+}
+
+//Option configures a Logger
+type Option func(*Logger)
+
+//To logs the entries to the output, which is Combined(os.Stdout) unless configured otherwise
+func To(output Output) Option {
+	return func(l *Logger) {
+		l.output = output
+	}
+}
+
+//Sample only logs the rate of requests, between 0 and 1. Responses with a server
+//error are always logged.
+func Sample(rate float64) Option {
+	return func(l *Logger) {
+		l.sample = rate
+	}
+}
+
+//Exclude doesn't log requests for the paths, such as health checks. The paths
+//can be patterns like the ones of routes, such as /static/*file.
+func Exclude(paths ...string) Option {
+	return func(l *Logger) {
+		l.excluded = append(l.excluded, paths...)
+	}
+}
+
+//New is a factory method for Logger
+func New(options ...Option) *Logger {
+	l := &Logger{
+		output: Combined(os.Stdout),
+		sample: 1,
+	}
+	for _, option := range options {
+		option(l)
+	}
+	return l
+}
+
+//AccessLog is a factory method for a Logger with the default options
+func AccessLog(inner http.Handler) http.Handler {
+	return New().ChainLink(inner)
+}
+
+//ChainLink logs the requests of the rest of the handler chain. Register
+//RouteChainLink instead to log the pattern of the route.
+func (l *Logger) ChainLink(inner http.Handler) http.Handler {
+	return l.handler("", inner)
+}
+
+//RouteChainLink logs the requests of the rest of the handler chain along with
+//the route's pattern.
+func (l *Logger) RouteChainLink(route *gonion.RouteModel) gonion.ChainLink {
+	return func(inner http.Handler) http.Handler {
+		return l.handler(route.Pattern, inner)
+	}
+}
+
+func (l *Logger) handler(route string, inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if l.isExcluded(r) {
+			inner.ServeHTTP(rw, r)
+			return
+		}
+		start := time.Now()
+		w := writer.Wrap(rw)
+		//requests that panic are logged as 500 while the panic carries on to the
+		//recovery middleware in front of the logger, with its stack intact
+		panicked := true
+		defer func() {
+			l.log(r, route, start, w, panicked)
+		}()
+		inner.ServeHTTP(w.ResponseWriter(), r)
+		panicked = false
+	})
+}
+
+//log logs the request once the rest of the chain returned or panicked
+func (l *Logger) log(r *http.Request, route string, start time.Time, w *writer.Writer, panicked bool) {
+	status, size := w.Status(), w.Size()
+	w.Release()
+	if panicked {
+		status = http.StatusInternalServerError
+	} else if status == 0 {
+		status = http.StatusOK
+	}
+	if l.sample < 1 && status < http.StatusInternalServerError && rand.Float64() >= l.sample {
+		return
+	}
+	l.output.Log(r.Context(), &Entry{
+		Time:       start,
+		RemoteAddr: remoteHost(r.RemoteAddr),
+		User:       user(r),
+		Method:     r.Method,
+		URI:        requestURI(r),
+		Proto:      r.Proto,
+		Route:      route,
+		Status:     status,
+		Size:       size,
+		Duration:   time.Since(start),
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+	})
+}
+
+func (l *Logger) isExcluded(r *http.Request) bool {
+	for _, path := range l.excluded {
+		if _, ok := gonion.MatchPattern(path, r.URL.Path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func requestURI(r *http.Request) string {
+	if r.RequestURI == "" && r.URL != nil {
+		return r.URL.RequestURI()
+	}
+	return r.RequestURI
+}
+
+func user(r *http.Request) string {
+	if username, _, ok := r.BasicAuth(); ok {
+		return username
+	}
+	return ""
+}
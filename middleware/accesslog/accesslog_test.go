@@ -0,0 +1,149 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/CoreyKaylor/gonion/middleware/recovery"
+	"github.com/stretchr/testify/assert"
+)
+
+var created = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+	rw.WriteHeader(http.StatusCreated)
+	rw.Write([]byte("created"))
+})
+
+func request(method string, target string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set("Referer", "http://example.com/")
+	r.Header.Set("User-Agent", "gonion-test")
+	r.SetBasicAuth("corey", "secret")
+	return r
+}
+
+func TestAccessLog_LogsTheMatchedRoute(t *testing.T) {
+	var entries []*Entry
+	logger := New(To(OutputFunc(func(ctx context.Context, entry *Entry) {
+		entries = append(entries, entry)
+	})))
+	g := gonion.New()
+	g.Use().RouteChainLink(logger.RouteChainLink)
+	g.Post("/users/:id", created)
+	g.BuildRoutes()[0].Handler.ServeHTTP(httptest.NewRecorder(), request("POST", "/users/1?notify=true"))
+	assert.Equal(t, len(entries), 1)
+	entry := entries[0]
+	assert.Equal(t, entry.Route, "/users/:id")
+	assert.Equal(t, entry.URI, "/users/1?notify=true")
+	assert.Equal(t, entry.RemoteAddr, "10.0.0.1")
+	assert.Equal(t, entry.User, "corey")
+	assert.Equal(t, entry.Status, http.StatusCreated)
+	assert.Equal(t, entry.Size, int64(7))
+}
+
+func TestAccessLog_FormatsCommonAndCombined(t *testing.T) {
+	entry := &Entry{
+		Time:       time.Date(2024, 3, 5, 14, 7, 9, 0, time.FixedZone("", -7*60*60)),
+		RemoteAddr: "10.0.0.1",
+		User:       "corey",
+		Method:     "GET",
+		URI:        "/users/1",
+		Proto:      "HTTP/1.1",
+		Status:     200,
+		Size:       512,
+		Referer:    "http://example.com/",
+		UserAgent:  "gonion-test",
+	}
+	var buffer bytes.Buffer
+	Common(&buffer).Log(context.Background(), entry)
+	assert.Equal(t, buffer.String(), "10.0.0.1 - corey [05/Mar/2024:14:07:09 -0700] \"GET /users/1 HTTP/1.1\" 200 512\n")
+	buffer.Reset()
+	entry.User = ""
+	entry.Size = 0
+	Combined(&buffer).Log(context.Background(), entry)
+	assert.Equal(t, buffer.String(), "10.0.0.1 - - [05/Mar/2024:14:07:09 -0700] \"GET /users/1 HTTP/1.1\" 200 - \"http://example.com/\" \"gonion-test\"\n")
+}
+
+func TestAccessLog_EscapesValuesFromTheRequest(t *testing.T) {
+	var buffer bytes.Buffer
+	r := request("GET", "/users")
+	r.SetBasicAuth("corey\"\n10.0.0.9 - admin", "secret")
+	r.Header.Set("User-Agent", "agent\\\"\r\nforged é")
+	New(To(Combined(&buffer))).ChainLink(created).ServeHTTP(httptest.NewRecorder(), r)
+	line := buffer.String()
+	assert.Equal(t, strings.Count(line, "\n"), 1)
+	assert.Contains(t, line, ` - corey\"\x0a10.0.0.9 - admin [`)
+	assert.True(t, strings.HasSuffix(line, ` "agent\\\"\x0d\x0aforged \xc3\xa9"`+"\n"))
+}
+
+func TestAccessLog_LogsRequestsThatPanic(t *testing.T) {
+	var entries []*Entry
+	logger := New(To(OutputFunc(func(ctx context.Context, entry *Entry) {
+		entries = append(entries, entry)
+	})), Sample(0))
+	var events []*recovery.Event
+	reporting := recovery.Reporting(recovery.ReporterFunc(func(ctx context.Context, event *recovery.Event) {
+		events = append(events, event)
+	}))
+	handler := recovery.New(reporting).ChainLink(logger.ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request("GET", "/users"))
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	assert.Equal(t, len(entries), 1)
+	assert.Equal(t, entries[0].Status, http.StatusInternalServerError)
+	assert.Equal(t, len(events), 1)
+	assert.Contains(t, events[0].Frames[0].Function, "TestAccessLog_LogsRequestsThatPanic")
+}
+
+func TestAccessLog_FormatsJSONAndSlog(t *testing.T) {
+	var buffer bytes.Buffer
+	handler := New(To(JSON(&buffer))).RouteChainLink(&gonion.RouteModel{Pattern: "/users/:id"})(created)
+	handler.ServeHTTP(httptest.NewRecorder(), request("GET", "/users/1"))
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &line))
+	assert.Equal(t, line["route"], "/users/:id")
+	assert.Equal(t, line["status"], float64(201))
+	assert.Equal(t, line["user_agent"], "gonion-test")
+
+	buffer.Reset()
+	handler = New(To(Slog(slog.New(slog.NewJSONHandler(&buffer, nil))))).ChainLink(created)
+	handler.ServeHTTP(httptest.NewRecorder(), request("GET", "/users/1"))
+	line = nil
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &line))
+	assert.Equal(t, line["msg"], "request")
+	assert.Equal(t, line["uri"], "/users/1")
+	assert.Equal(t, line["size"], float64(7))
+}
+
+func TestAccessLog_SamplesAndExcludes(t *testing.T) {
+	var buffer bytes.Buffer
+	failing := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			rw.WriteHeader(http.StatusBadGateway)
+		}
+	})
+	handler := New(To(Common(&buffer)), Sample(0), Exclude("/health", "/static/*file")).ChainLink(failing)
+	for _, path := range []string{"/users", "/health", "/static/app.js", "/fail"} {
+		handler.ServeHTTP(httptest.NewRecorder(), request("GET", path))
+	}
+	assert.Equal(t, strings.Count(buffer.String(), "\n"), 1)
+	assert.True(t, strings.Contains(buffer.String(), `"GET /fail HTTP/1.1" 502`))
+
+	buffer.Reset()
+	handler = New(To(Common(&buffer)), Exclude("/health")).ChainLink(failing)
+	for _, path := range []string{"/users", "/health"} {
+		handler.ServeHTTP(httptest.NewRecorder(), request("GET", path))
+	}
+	assert.Equal(t, strings.Count(buffer.String(), "\n"), 1)
+	assert.True(t, strings.Contains(buffer.String(), `"GET /users HTTP/1.1" 200 -`))
+}
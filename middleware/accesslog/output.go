@@ -0,0 +1,172 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//Output is where the entries are logged
+type Output interface {
+	Log(context.Context, *Entry)
+}
+
+//OutputFunc is a simple func that represents an Output
+type OutputFunc func(context.Context, *Entry)
+
+//Log is the OutputFunc implementation of the Output interface
+func (f OutputFunc) Log(ctx context.Context, entry *Entry) {
+	f(ctx, entry)
+}
+
+//writerOutput formats each entry as a line written to the writer at once
+type writerOutput struct {
+	mu     sync.Mutex
+	writer io.Writer
+	format func(*bytes.Buffer, *Entry)
+}
+
+func (o *writerOutput) Log(ctx context.Context, entry *Entry) {
+	var line bytes.Buffer
+	o.format(&line, entry)
+	line.WriteByte('\n')
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.writer.Write(line.Bytes())
+}
+
+//Common logs the entries to the writer in the Common Log Format
+func Common(w io.Writer) Output {
+	return &writerOutput{writer: w, format: formatCommon}
+}
+
+//Combined logs the entries to the writer in the Combined Log Format, which is
+//the Common Log Format with the referer and user agent.
+func Combined(w io.Writer) Output {
+	return &writerOutput{writer: w, format: func(line *bytes.Buffer, entry *Entry) {
+		formatCommon(line, entry)
+		line.WriteString(` "`)
+		writeEscaped(line, orDash(entry.Referer))
+		line.WriteString(`" "`)
+		writeEscaped(line, orDash(entry.UserAgent))
+		line.WriteString(`"`)
+	}}
+}
+
+func formatCommon(line *bytes.Buffer, entry *Entry) {
+	writeEscaped(line, orDash(entry.RemoteAddr))
+	line.WriteString(" - ")
+	writeEscaped(line, orDash(entry.User))
+	line.WriteString(" [")
+	line.WriteString(entry.Time.Format("02/Jan/2006:15:04:05 -0700"))
+	line.WriteString(`] "`)
+	writeEscaped(line, entry.Method)
+	line.WriteString(" ")
+	writeEscaped(line, entry.URI)
+	line.WriteString(" ")
+	writeEscaped(line, entry.Proto)
+	line.WriteString(`" `)
+	line.WriteString(strconv.Itoa(entry.Status))
+	line.WriteString(" ")
+	if entry.Size == 0 {
+		line.WriteString("-")
+	} else {
+		line.WriteString(strconv.FormatInt(entry.Size, 10))
+	}
+}
+
+//writeEscaped writes a value from the request the way Apache's mod_log_config
+//does, so it can't end its quotes or forge lines. Quotes and backslashes are
+//escaped with a backslash, and control and non-ASCII bytes are written as \xhh.
+func writeEscaped(line *bytes.Buffer, value string) {
+	const hex = "0123456789abcdef"
+	for i := 0; i < len(value); i++ {
+		switch b := value[i]; {
+		case b == '"' || b == '\\':
+			line.WriteByte('\\')
+			line.WriteByte(b)
+		case b < 0x20 || b >= 0x7f:
+			line.WriteString(`\x`)
+			line.WriteByte(hex[b>>4])
+			line.WriteByte(hex[b&0xf])
+		default:
+			line.WriteByte(b)
+		}
+	}
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+type jsonEntry struct {
+	Time       string  `json:"time"`
+	RemoteAddr string  `json:"remote_addr"`
+	User       string  `json:"user,omitempty"`
+	Method     string  `json:"method"`
+	URI        string  `json:"uri"`
+	Proto      string  `json:"proto"`
+	Route      string  `json:"route,omitempty"`
+	Status     int     `json:"status"`
+	Size       int64   `json:"size"`
+	Duration   float64 `json:"duration_ms"`
+	Referer    string  `json:"referer,omitempty"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+}
+
+//JSON logs the entries to the writer as one JSON object per line
+func JSON(w io.Writer) Output {
+	return &writerOutput{writer: w, format: func(line *bytes.Buffer, entry *Entry) {
+		data, _ := json.Marshal(&jsonEntry{
+			Time:       entry.Time.Format(time.RFC3339Nano),
+			RemoteAddr: entry.RemoteAddr,
+			User:       entry.User,
+			Method:     entry.Method,
+			URI:        entry.URI,
+			Proto:      entry.Proto,
+			Route:      entry.Route,
+			Status:     entry.Status,
+			Size:       entry.Size,
+			Duration:   float64(entry.Duration) / float64(time.Millisecond),
+			Referer:    entry.Referer,
+			UserAgent:  entry.UserAgent,
+		})
+		line.Write(data)
+	}}
+}
+
+//Slog logs the entries to the slog logger at the info level
+func Slog(logger *slog.Logger) Output {
+	return OutputFunc(func(ctx context.Context, entry *Entry) {
+		attrs := []slog.Attr{
+			slog.String("remote_addr", entry.RemoteAddr),
+			slog.String("method", entry.Method),
+			slog.String("uri", entry.URI),
+			slog.String("proto", entry.Proto),
+			slog.Int("status", entry.Status),
+			slog.Int64("size", entry.Size),
+			slog.Duration("duration", entry.Duration),
+		}
+		if entry.Route != "" {
+			attrs = append(attrs, slog.String("route", entry.Route))
+		}
+		if entry.User != "" {
+			attrs = append(attrs, slog.String("user", entry.User))
+		}
+		if entry.Referer != "" {
+			attrs = append(attrs, slog.String("referer", entry.Referer))
+		}
+		if entry.UserAgent != "" {
+			attrs = append(attrs, slog.String("user_agent", entry.UserAgent))
+		}
+		logger.LogAttrs(ctx, slog.LevelInfo, "request", attrs...)
+	})
+}
@@ -0,0 +1,175 @@
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/CoreyKaylor/gonion/negotiation"
+	"github.com/CoreyKaylor/gonion/writer"
+)
+
+//DefaultMinSize is the size in bytes below which responses aren't compressed,
+//since compressing them saves little or even grows them.
+const DefaultMinSize = 1024
+
+//DefaultContentTypes are the media types that are compressed by default. Types
+//that are already compressed, such as images and archives, aren't among them.
+var DefaultContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/javascript",
+	"application/xml",
+	"application/*+xml",
+	"image/svg+xml",
+}
+
+//Compressor compresses responses with the content coding the request prefers
+type Compressor struct {
+	level        int
+	minSize      int
+	contentTypes []string
+	gzipPool     sync.Pool
+	deflatePool  sync.Pool
+}
+
+//Option configures a Compressor
+type Option func(*Compressor)
+
+//Level is the compression level, flate.DefaultCompression unless configured otherwise
+func Level(level int) Option {
+	return func(c *Compressor) {
+		c.level = level
+	}
+}
+
+//MinSize is the size in bytes below which responses aren't compressed
+func MinSize(size int) Option {
+	return func(c *Compressor) {
+		c.minSize = size
+	}
+}
+
+//ContentTypes replaces the media types that are compressed. A type can end with
+//a * to match any subtype, such as text/*, or start its subtype with *+ to match a
+//structured syntax suffix, such as application/*+json.
+func ContentTypes(types ...string) Option {
+	return func(c *Compressor) {
+		c.contentTypes = types
+	}
+}
+
+//New is a factory method for Compressor. It panics when the level isn't valid.
+func New(options ...Option) *Compressor {
+	c := &Compressor{
+		level:        flate.DefaultCompression,
+		minSize:      DefaultMinSize,
+		contentTypes: DefaultContentTypes,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if _, err := gzip.NewWriterLevel(io.Discard, c.level); err != nil {
+		panic(err)
+	}
+	c.gzipPool.New = func() interface{} {
+		writer, _ := gzip.NewWriterLevel(io.Discard, c.level)
+		return writer
+	}
+	c.deflatePool.New = func() interface{} {
+		writer, _ := flate.NewWriter(io.Discard, c.level)
+		return writer
+	}
+	return c
+}
+
+//Compress is a factory method for a Compressor with the default options
+func Compress(inner http.Handler) http.Handler {
+	return New().ChainLink(inner)
+}
+
+//ChainLink compresses the responses of the rest of the handler chain
+func (c *Compressor) ChainLink(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			inner.ServeHTTP(rw, r)
+			return
+		}
+		compressing := &responseWriter{
+			ResponseWriter: rw,
+			compressor:     c,
+			encoding:       c.encodingFor(r),
+		}
+		w := writer.Through(rw, compressing)
+		inner.ServeHTTP(w.ResponseWriter(), r)
+		hijacked := w.Hijacked()
+		w.Release()
+		if !hijacked {
+			compressing.close()
+		}
+	})
+}
+
+//encodingFor negotiates the content coding, requests without Accept-Encoding
+//aren't compressed.
+func (c *Compressor) encodingFor(r *http.Request) string {
+	if r.Header.Get("Accept-Encoding") == "" {
+		return ""
+	}
+	switch encoding := negotiation.NegotiateEncoding(r, "gzip", "deflate", "identity"); encoding {
+	case "gzip", "deflate":
+		return encoding
+	}
+	return ""
+}
+
+func (c *Compressor) compresses(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.contentTypes {
+		switch {
+		case t == mediaType:
+			return true
+		case strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1]):
+			return true
+		case strings.Contains(t, "/*+"):
+			slash := strings.Index(t, "/")
+			if strings.HasPrefix(mediaType, t[:slash+1]) && strings.HasSuffix(mediaType, t[slash+2:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Compressor) writer(encoding string, w io.Writer) compressWriter {
+	if encoding == "gzip" {
+		writer := c.gzipPool.Get().(*gzip.Writer)
+		writer.Reset(w)
+		return writer
+	}
+	writer := c.deflatePool.Get().(*flate.Writer)
+	writer.Reset(w)
+	return writer
+}
+
+func (c *Compressor) release(writer compressWriter) {
+	switch writer := writer.(type) {
+	case *gzip.Writer:
+		c.gzipPool.Put(writer)
+	case *flate.Writer:
+		c.deflatePool.Put(writer)
+	}
+}
+
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
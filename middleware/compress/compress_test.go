@@ -0,0 +1,174 @@
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/stretchr/testify/assert"
+)
+
+var large = strings.Repeat(`{"name":"gonion"}`, 100)
+
+func writes(contentType string, body string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			rw.Header().Set("Content-Type", contentType)
+		}
+		rw.Header().Set("Content-Length", "1700")
+		rw.Write([]byte(body[:len(body)/2]))
+		rw.Write([]byte(body[len(body)/2:]))
+	})
+}
+
+func serve(handler http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("GET", "/", nil)
+	if acceptEncoding != "" {
+		request.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func gunzip(t *testing.T, body io.Reader) string {
+	reader, err := gzip.NewReader(body)
+	assert.Nil(t, err)
+	data, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func TestCompress_GzipsLargeResponses(t *testing.T) {
+	handler := Compress(writes("application/json", large))
+	for i := 0; i < 2; i++ {
+		recorder := serve(handler, "deflate;q=0.5, gzip")
+		assert.Equal(t, recorder.Header().Get("Content-Encoding"), "gzip")
+		assert.Equal(t, recorder.Header().Get("Vary"), "Accept-Encoding")
+		assert.Equal(t, recorder.Header().Get("Content-Length"), "")
+		assert.Equal(t, gunzip(t, recorder.Body), large)
+	}
+}
+
+func TestCompress_DeflatesWhenPreferred(t *testing.T) {
+	recorder := serve(Compress(writes("text/html; charset=utf-8", large)), "gzip;q=0.5, deflate")
+	assert.Equal(t, recorder.Header().Get("Content-Encoding"), "deflate")
+	data, err := io.ReadAll(flate.NewReader(recorder.Body))
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), large)
+}
+
+func TestCompress_SkipsWhatShouldNotBeCompressed(t *testing.T) {
+	recorder := serve(Compress(writes("application/json", large)), "")
+	assert.Equal(t, recorder.Header().Get("Content-Encoding"), "")
+	assert.Equal(t, recorder.Header().Get("Vary"), "Accept-Encoding")
+	assert.Equal(t, recorder.Body.String(), large)
+
+	recorder = serve(Compress(writes("application/json", large)), "br")
+	assert.Equal(t, recorder.Header().Get("Content-Encoding"), "")
+
+	recorder = serve(Compress(writes("image/png", large)), "gzip")
+	assert.Equal(t, recorder.Header().Get("Content-Encoding"), "")
+	assert.Equal(t, recorder.Header().Get("Vary"), "")
+	assert.Equal(t, recorder.Header().Get("Content-Length"), "1700")
+
+	small := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte(`{"id":1}`))
+	})
+	recorder = serve(Compress(small), "gzip")
+	assert.Equal(t, recorder.Code, http.StatusCreated)
+	assert.Equal(t, recorder.Header().Get("Content-Encoding"), "")
+	assert.Equal(t, recorder.Body.String(), `{"id":1}`)
+}
+
+func TestCompress_SkipsRangeResponses(t *testing.T) {
+	content := strings.NewReader(large)
+	handler := Compress(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		http.ServeContent(rw, r, "users.json", time.Time{}, content)
+	}))
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	request.Header.Set("Range", "bytes=0-1199")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, recorder.Code, http.StatusPartialContent)
+	assert.Equal(t, recorder.Header().Get("Content-Encoding"), "")
+	assert.Equal(t, recorder.Header().Get("Content-Length"), "1200")
+	assert.Equal(t, recorder.Header().Get("Content-Range"), "bytes 0-1199/1700")
+	assert.Equal(t, recorder.Body.String(), large[:1200])
+}
+
+func TestCompress_SniffsTheContentType(t *testing.T) {
+	recorder := serve(Compress(writes("", "<html>"+strings.Repeat("gonion ", 300)+"</html>")), "gzip")
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "text/html; charset=utf-8")
+	assert.Equal(t, recorder.Header().Get("Content-Encoding"), "gzip")
+}
+
+func TestCompress_FlushesWhileStreaming(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	handler := Compress(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Write([]byte("data: 1\n\n"))
+		rw.(http.Flusher).Flush()
+		assert.True(t, recorder.Flushed)
+		rw.Write([]byte("data: 2\n\n"))
+	}))
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, recorder.Header().Get("Content-Encoding"), "gzip")
+	assert.Equal(t, gunzip(t, recorder.Body), "data: 1\n\ndata: 2\n\n")
+}
+
+func TestCompress_KeepsTheInterfacesOfTheWrappedWriter(t *testing.T) {
+	server := httptest.NewServer(Compress(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, ok := rw.(io.ReaderFrom)
+		assert.True(t, ok)
+		conn, buffer, err := rw.(http.Hijacker).Hijack()
+		assert.Nil(t, err)
+		defer conn.Close()
+		buffer.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		buffer.Flush()
+	})))
+	defer server.Close()
+	request, _ := http.NewRequest("GET", server.URL, nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	response, err := http.DefaultTransport.RoundTrip(request)
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(response.Body)
+	assert.Equal(t, string(body), "hijacked")
+}
+
+func TestCompress_CompressesWhatIsReadFrom(t *testing.T) {
+	handler := Compress(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		io.Copy(rw, strings.NewReader(large))
+	}))
+	recorder := serve(handler, "gzip")
+	assert.Equal(t, recorder.Header().Get("Content-Encoding"), "gzip")
+	assert.Equal(t, gunzip(t, recorder.Body), large)
+}
+
+func TestCompress_CanBeConstrainedToRoutes(t *testing.T) {
+	g := gonion.New()
+	g.Only().Get().Use().ChainLink(New(MinSize(0), ContentTypes("application/*+json")).ChainLink)
+	g.Get("/", writes("application/problem+json", large))
+	g.Post("/", writes("application/problem+json", large))
+	for _, route := range g.BuildRoutes() {
+		recorder := serve(route.Handler, "gzip")
+		if route.Method == "GET" {
+			assert.Equal(t, recorder.Header().Get("Content-Encoding"), "gzip")
+		} else {
+			assert.Equal(t, recorder.Header().Get("Content-Encoding"), "")
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package compress
+
+import (
+	"net/http"
+	"strconv"
+)
+
+//responseWriter buffers the start of the response until it knows whether to
+//compress it, which is once the buffer reaches the minimum size, the handler
+//flushes or the handler returns.
+type responseWriter struct {
+	http.ResponseWriter
+	compressor *Compressor
+	encoding   string
+	status     int
+	buffer     []byte
+	decided    bool
+	writer     compressWriter
+}
+
+//WriteHeader holds on to the status until it's decided whether to compress,
+//informational statuses are written right away.
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.decided || status < http.StatusOK {
+		rw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if rw.status == 0 {
+		rw.status = status
+	}
+}
+
+func (rw *responseWriter) Write(data []byte) (int, error) {
+	if !rw.decided {
+		rw.buffer = append(rw.buffer, data...)
+		if len(rw.buffer) < rw.compressor.minSize {
+			return len(data), nil
+		}
+		return len(data), rw.decide(true)
+	}
+	if rw.writer != nil {
+		return rw.writer.Write(data)
+	}
+	return rw.ResponseWriter.Write(data)
+}
+
+//Flush is the implementation of http.Flusher. Flushing before the minimum size
+//is reached still compresses the response, since it's being streamed.
+func (rw *responseWriter) Flush() {
+	if !rw.decided {
+		rw.decide(true)
+	}
+	if rw.writer != nil {
+		rw.writer.Flush()
+	}
+	http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+//Unwrap returns the wrapped writer for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+//decide writes the header, compressing the response when it's eligible. Large
+//is whether the response reached the minimum size.
+func (rw *responseWriter) decide(large bool) error {
+	rw.decided = true
+	header := rw.Header()
+	status := rw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if header.Get("Content-Type") == "" && len(rw.buffer) > 0 {
+		header.Set("Content-Type", http.DetectContentType(rw.buffer))
+	}
+	//compressing a range would change the length and offsets it refers to
+	eligible := status != http.StatusNoContent && status != http.StatusNotModified &&
+		status != http.StatusPartialContent && header.Get("Content-Range") == "" &&
+		header.Get("Content-Encoding") == "" && rw.compressor.compresses(header.Get("Content-Type"))
+	if eligible {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < rw.compressor.minSize {
+		large = false
+	}
+	if eligible && large && rw.encoding != "" {
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		header.Set("Content-Encoding", rw.encoding)
+		rw.writer = rw.compressor.writer(rw.encoding, rw.ResponseWriter)
+	}
+	rw.ResponseWriter.WriteHeader(status)
+	buffer := rw.buffer
+	rw.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+	var err error
+	if rw.writer != nil {
+		_, err = rw.writer.Write(buffer)
+	} else {
+		_, err = rw.ResponseWriter.Write(buffer)
+	}
+	return err
+}
+
+//close finishes the response once the handler returns. It isn't called when
+//the handler panics, so the recovery middleware can still respond when nothing
+//was written yet.
+func (rw *responseWriter) close() {
+	if !rw.decided {
+		if rw.status == 0 && len(rw.buffer) == 0 {
+			return
+		}
+		rw.decide(false)
+	}
+	if rw.writer != nil {
+		rw.writer.Close()
+		rw.compressor.release(rw.writer)
+		rw.writer = nil
+	}
+}
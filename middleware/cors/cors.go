@@ -0,0 +1,315 @@
+package cors
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/CoreyKaylor/gonion/openapi"
+)
+
+//CORS answers preflight requests and adds the CORS headers to the responses of
+//the routes it's applied to
+type CORS struct {
+	origins       []string
+	originFunc    func(origin string, r *http.Request) bool
+	methods       []string
+	headers       []string
+	exposeHeaders string
+	credentials   bool
+	maxAge        string
+}
+
+//Option configures CORS
+type Option func(*CORS)
+
+//Origins allows requests from the origins, which can be exact like https://example.com,
+//a wildcard for subdomains like https://*.example.com, or * for any origin.
+func Origins(origins ...string) Option {
+	return func(c *CORS) {
+		for _, origin := range origins {
+			c.origins = append(c.origins, strings.ToLower(origin))
+		}
+	}
+}
+
+//OriginFunc allows requests from the origins the func returns true for, in addition
+//to the ones allowed by Origins
+func OriginFunc(allowed func(origin string, r *http.Request) bool) Option {
+	return func(c *CORS) {
+		c.originFunc = allowed
+	}
+}
+
+//Methods limits the methods allowed from other origins, which are otherwise all
+//of the methods of a route's pattern
+func Methods(methods ...string) Option {
+	return func(c *CORS) {
+		for _, method := range methods {
+			c.methods = append(c.methods, strings.ToUpper(method))
+		}
+	}
+}
+
+//Headers are the request headers allowed from other origins, which are Accept,
+//Content-Type and X-Requested-With unless configured otherwise. * allows any header.
+func Headers(headers ...string) Option {
+	return func(c *CORS) {
+		c.headers = nil
+		for _, header := range headers {
+			c.headers = append(c.headers, http.CanonicalHeaderKey(header))
+		}
+	}
+}
+
+//ExposeHeaders are the response headers scripts from other origins can read
+func ExposeHeaders(headers ...string) Option {
+	return func(c *CORS) {
+		c.exposeHeaders = strings.Join(headers, ", ")
+	}
+}
+
+//Credentials allows requests from other origins to include cookies and
+//authorization headers. It can't be combined with allowing any origin or with
+//wildcards other than subdomains of a fixed host like https://*.example.com,
+//since any site could then read responses with the user's credentials.
+func Credentials() Option {
+	return func(c *CORS) {
+		c.credentials = true
+	}
+}
+
+//MaxAge is how long browsers can cache the answer to a preflight request
+func MaxAge(maxAge time.Duration) Option {
+	return func(c *CORS) {
+		c.maxAge = strconv.Itoa(int(maxAge / time.Second))
+	}
+}
+
+//New is a factory method for CORS
+func New(options ...Option) *CORS {
+	c := &CORS{
+		headers: []string{"Accept", "Content-Type", "X-Requested-With"},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.credentials && contains(c.origins, "*") {
+		panic("cors: credentials can't be allowed for any origin, list the origins or use OriginFunc")
+	}
+	for _, origin := range c.origins {
+		if c.credentials && origin != "*" && strings.Contains(origin, "*") && !subdomainWildcard(origin) {
+			panic("cors: credentials can only be allowed for wildcard origins like scheme://*.host.tld, not " + origin)
+		}
+	}
+	return c
+}
+
+//Apply registers CORS for the routes under the composer's path and adds an
+//OPTIONS route for each of their patterns that doesn't have one, so preflight
+//requests are answered with the methods of the pattern. The OPTIONS routes are
+//hidden from OpenAPI documents. The same CORS can be applied to several composers.
+func (c *CORS) Apply(composer *gonion.Composer) {
+	//the methods of each pattern under this composer, from the latest build
+	patterns := make(map[string][]string)
+	composer.Use().Named("cors").RouteChainLink(func(route *gonion.RouteModel) gonion.ChainLink {
+		methods, ok := patterns[route.Pattern]
+		if !ok {
+			methods = []string{route.Method}
+		}
+		return c.chainLink(methods)
+	})
+	composer.BeforeBuild(func(routes []*gonion.RouteModel) {
+		for pattern := range patterns {
+			delete(patterns, pattern)
+		}
+		options := make(map[string]bool)
+		for _, route := range routes {
+			if route.Method == http.MethodOptions {
+				options[route.Pattern] = true
+			} else {
+				patterns[route.Pattern] = appendMethod(patterns[route.Pattern], route.Method)
+			}
+		}
+		for _, route := range routes {
+			if !options[route.Pattern] {
+				options[route.Pattern] = true
+				composer.Handle(http.MethodOptions, route.Pattern[len(composer.Path()):], allow(patterns[route.Pattern])).
+					Meta(openapi.HiddenKey, true)
+			}
+		}
+	})
+}
+
+func appendMethod(methods []string, method string) []string {
+	for _, m := range methods {
+		if m == method {
+			return methods
+		}
+	}
+	methods = append(methods, method)
+	sort.Strings(methods)
+	return methods
+}
+
+//allow answers OPTIONS requests that aren't preflight requests with the methods
+//of the pattern
+func allow(methods []string) http.Handler {
+	header := strings.Join(append(methods[:len(methods):len(methods)], http.MethodOptions), ", ")
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Allow", header)
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
+
+//RouteChainLink answers preflight requests for the route's pattern and adds the
+//CORS headers to the responses of the rest of the handler chain. Registered on
+//its own rather than with Apply, preflight requests are allowed the route's own
+//method.
+func (c *CORS) RouteChainLink(route *gonion.RouteModel) gonion.ChainLink {
+	return c.chainLink([]string{route.Method})
+}
+
+//chainLink is the RouteChainLink for a pattern with the methods
+func (c *CORS) chainLink(methods []string) gonion.ChainLink {
+	methods = c.allowed(methods)
+	allowMethods := strings.Join(methods, ", ")
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			//the response depends on the origin even without one, so caches don't
+			//serve a response without the CORS headers to other origins
+			rw.Header().Add("Vary", "Origin")
+			origin := r.Header.Get("Origin")
+			if r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+				c.preflight(rw, r, origin, methods, allowMethods)
+				return
+			}
+			if origin != "" {
+				c.actual(rw, r, origin)
+			}
+			inner.ServeHTTP(rw, r)
+		})
+	}
+}
+
+//allowed is the methods that Methods allows
+func (c *CORS) allowed(methods []string) []string {
+	if c.methods == nil {
+		return methods
+	}
+	var allowed []string
+	for _, method := range methods {
+		if contains(c.methods, method) {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+func (c *CORS) preflight(rw http.ResponseWriter, r *http.Request, origin string, methods []string, allowMethods string) {
+	header := rw.Header()
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	requestHeaders := r.Header.Get("Access-Control-Request-Headers")
+	if !c.allowsOrigin(origin, r) || !contains(methods, r.Header.Get("Access-Control-Request-Method")) || !c.allowsHeaders(requestHeaders) {
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+	c.allowOrigin(header, origin)
+	header.Set("Access-Control-Allow-Methods", allowMethods)
+	if requestHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", requestHeaders)
+	}
+	if c.maxAge != "" {
+		header.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (c *CORS) actual(rw http.ResponseWriter, r *http.Request, origin string) {
+	header := rw.Header()
+	if !c.allowsOrigin(origin, r) {
+		return
+	}
+	c.allowOrigin(header, origin)
+	if c.exposeHeaders != "" {
+		header.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+	}
+}
+
+//allowOrigin echoes the origin unless any origin is allowed, which New only
+//allows without credentials
+func (c *CORS) allowOrigin(header http.Header, origin string) {
+	if contains(c.origins, "*") {
+		header.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if c.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *CORS) allowsOrigin(origin string, r *http.Request) bool {
+	lower := strings.ToLower(origin)
+	for _, allowed := range c.origins {
+		if allowed == "*" || allowed == lower {
+			return true
+		}
+		if i := strings.Index(allowed, "*"); i >= 0 {
+			prefix, suffix := allowed[:i], allowed[i+1:]
+			if len(lower) > len(prefix)+len(suffix) && strings.HasPrefix(lower, prefix) && strings.HasSuffix(lower, suffix) {
+				return true
+			}
+		}
+	}
+	return c.originFunc != nil && c.originFunc(origin, r)
+}
+
+//subdomainWildcard is whether the wildcard origin only matches the subdomains of
+//a fixed host, like https://*.example.com, rather than hosts anyone can register
+func subdomainWildcard(origin string) bool {
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok || scheme == "" || strings.Contains(scheme, "*") {
+		return false
+	}
+	host, ok = strings.CutPrefix(host, "*.")
+	if !ok || strings.ContainsAny(host, "*/") {
+		return false
+	}
+	hostname, _, _ := strings.Cut(host, ":")
+	labels := strings.Split(hostname, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *CORS) allowsHeaders(requested string) bool {
+	if requested == "" || contains(c.headers, "*") {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		if !contains(c.headers, http.CanonicalHeaderKey(strings.TrimSpace(header))) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/CoreyKaylor/gonion/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+var ok = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+	rw.Write([]byte("ok"))
+})
+
+func routes(c *CORS) gonion.Routes {
+	g := gonion.New()
+	g.Get("/admin", ok)
+	g.Sub("/api", func(api *gonion.Composer) {
+		c.Apply(api)
+		api.Get("/users", ok)
+		api.Post("/users", ok)
+		api.Delete("/users/:id", ok)
+	})
+	return g.BuildRoutes()
+}
+
+func route(routes gonion.Routes, method string, pattern string) *gonion.Route {
+	for _, r := range routes {
+		if r.Method == method && r.Pattern == pattern {
+			return r
+		}
+	}
+	return nil
+}
+
+func preflight(target string, origin string, method string, headers string) *http.Request {
+	r := httptest.NewRequest("OPTIONS", target, nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		r.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return r
+}
+
+func TestCORS_AddsOptionsRoutesOnlyUnderThePath(t *testing.T) {
+	built := routes(New(Origins("https://example.com")))
+	assert.NotNil(t, route(built, "OPTIONS", "/api/users"))
+	assert.NotNil(t, route(built, "OPTIONS", "/api/users/:id"))
+	assert.Nil(t, route(built, "OPTIONS", "/admin"))
+
+	recorder := httptest.NewRecorder()
+	route(built, "OPTIONS", "/api/users").Handler.ServeHTTP(recorder, httptest.NewRequest("OPTIONS", "/api/users", nil))
+	assert.Equal(t, recorder.Code, http.StatusNoContent)
+	assert.Equal(t, recorder.Header().Get("Allow"), "GET, POST, OPTIONS")
+}
+
+func TestCORS_AnswersPreflightWithTheMethodsOfThePattern(t *testing.T) {
+	built := routes(New(Origins("https://example.com"), Headers("Content-Type", "Authorization"), MaxAge(10*time.Minute)))
+	recorder := httptest.NewRecorder()
+	route(built, "OPTIONS", "/api/users").Handler.ServeHTTP(recorder, preflight("/api/users", "https://example.com", "POST", "content-type, authorization"))
+	assert.Equal(t, recorder.Code, http.StatusNoContent)
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Origin"), "https://example.com")
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Methods"), "GET, POST")
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Headers"), "content-type, authorization")
+	assert.Equal(t, recorder.Header().Get("Access-Control-Max-Age"), "600")
+	assert.Equal(t, recorder.Header().Values("Vary"), []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"})
+
+	recorder = httptest.NewRecorder()
+	route(built, "OPTIONS", "/api/users/:id").Handler.ServeHTTP(recorder, preflight("/api/users/1", "https://example.com", "DELETE", ""))
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Methods"), "DELETE")
+}
+
+func TestCORS_RejectedPreflightHasNoCORSHeaders(t *testing.T) {
+	c := New(Origins("https://example.com"), Methods("GET"))
+	built := routes(c)
+	cases := []*http.Request{
+		preflight("/api/users", "https://evil.com", "GET", ""),
+		preflight("/api/users", "https://example.com", "POST", ""),
+		preflight("/api/users", "https://example.com", "GET", "X-Custom"),
+	}
+	for _, r := range cases {
+		recorder := httptest.NewRecorder()
+		route(built, "OPTIONS", "/api/users").Handler.ServeHTTP(recorder, r)
+		assert.Equal(t, recorder.Code, http.StatusNoContent)
+		assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Origin"), "")
+		assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Methods"), "")
+	}
+}
+
+func TestCORS_MatchesOrigins(t *testing.T) {
+	c := New(Origins("https://example.com", "https://*.example.org"), OriginFunc(func(origin string, r *http.Request) bool {
+		return strings.HasSuffix(origin, ".internal")
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	assert.True(t, c.allowsOrigin("https://EXAMPLE.com", r))
+	assert.True(t, c.allowsOrigin("https://app.example.org", r))
+	assert.False(t, c.allowsOrigin("https://example.org", r))
+	assert.False(t, c.allowsOrigin("https://example.org.evil.com", r))
+	assert.True(t, c.allowsOrigin("http://tools.internal", r))
+	assert.False(t, c.allowsOrigin("https://example.net", r))
+}
+
+func TestCORS_AddsHeadersToActualRequests(t *testing.T) {
+	built := routes(New(Origins("*"), ExposeHeaders("X-Total-Count")))
+	r := httptest.NewRequest("GET", "/api/users", nil)
+	r.Header.Set("Origin", "https://example.com")
+	recorder := httptest.NewRecorder()
+	route(built, "GET", "/api/users").Handler.ServeHTTP(recorder, r)
+	assert.Equal(t, recorder.Body.String(), "ok")
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Origin"), "*")
+	assert.Equal(t, recorder.Header().Get("Access-Control-Expose-Headers"), "X-Total-Count")
+
+	recorder = httptest.NewRecorder()
+	route(built, "GET", "/admin").Handler.ServeHTTP(recorder, r)
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Origin"), "")
+	assert.Equal(t, recorder.Header().Get("Vary"), "")
+}
+
+func TestCORS_VariesByOriginEvenWithoutOne(t *testing.T) {
+	built := routes(New(Origins("https://example.com")))
+	recorder := httptest.NewRecorder()
+	route(built, "GET", "/api/users").Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/users", nil))
+	assert.Equal(t, recorder.Header().Values("Vary"), []string{"Origin"})
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Origin"), "")
+}
+
+func TestCORS_EchoesTheOriginWithCredentials(t *testing.T) {
+	built := routes(New(Origins("https://*.example.com"), Credentials()))
+	r := httptest.NewRequest("GET", "/api/users", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	recorder := httptest.NewRecorder()
+	route(built, "GET", "/api/users").Handler.ServeHTTP(recorder, r)
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Origin"), "https://app.example.com")
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Credentials"), "true")
+	assert.Equal(t, recorder.Header().Get("Vary"), "Origin")
+}
+
+func TestCORS_RefusesCredentialsForAnyOrigin(t *testing.T) {
+	assert.PanicsWithValue(t, "cors: credentials can't be allowed for any origin, list the origins or use OriginFunc", func() {
+		New(Origins("*"), Credentials())
+	})
+	assert.NotPanics(t, func() {
+		New(OriginFunc(func(origin string, r *http.Request) bool { return true }), Credentials())
+	})
+	for _, origin := range []string{"https://*", "https://*.com", "https://*example.com", "*.example.com", "https://*.*.example.com", "https://app.*.com"} {
+		assert.Panics(t, func() {
+			New(Origins(origin), Credentials())
+		}, origin)
+		assert.NotPanics(t, func() {
+			New(Origins(origin))
+		}, origin)
+	}
+	assert.NotPanics(t, func() {
+		New(Origins("https://*.example.com", "http://*.example.com:8080"), Credentials())
+	})
+}
+
+func TestCORS_BuildingAgainDoesNotAddMoreRoutes(t *testing.T) {
+	g := gonion.New()
+	New(Origins("*")).Apply(g)
+	g.Get("/users", ok)
+	g.Post("/users", ok)
+	g.Handle("OPTIONS", "/health", ok)
+	assert.Equal(t, len(g.BuildRoutes()), 4)
+	built := g.BuildRoutes()
+	assert.Equal(t, len(built), 4)
+	recorder := httptest.NewRecorder()
+	route(built, "OPTIONS", "/users").Handler.ServeHTTP(recorder, preflight("/users", "https://example.com", "POST", ""))
+	assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Methods"), "GET, POST")
+}
+
+func TestCORS_CanBeAppliedToSeveralComposers(t *testing.T) {
+	c := New(Origins("https://example.com"))
+	g := gonion.New()
+	g.Sub("/api", func(api *gonion.Composer) {
+		c.Apply(api)
+		api.Get("/users", ok)
+		api.Post("/users", ok)
+	})
+	g.Sub("/public", func(public *gonion.Composer) {
+		c.Apply(public)
+		public.Get("/docs", ok)
+	})
+	built := g.BuildRoutes()
+	for pattern, methods := range map[string]string{"/api/users": "GET, POST", "/public/docs": "GET"} {
+		recorder := httptest.NewRecorder()
+		route(built, "OPTIONS", pattern).Handler.ServeHTTP(recorder, preflight(pattern, "https://example.com", "GET", ""))
+		assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Origin"), "https://example.com", pattern)
+		assert.Equal(t, recorder.Header().Get("Access-Control-Allow-Methods"), methods, pattern)
+	}
+}
+
+func TestCORS_HidesTheOptionsRoutesFromOpenAPI(t *testing.T) {
+	g := gonion.New()
+	New(Origins("*")).Apply(g)
+	g.Get("/users", ok)
+	doc := openapi.Generate(g.BuildRoutes(), openapi.Config{})
+	assert.NotNil(t, doc.Paths["/users"].Get)
+	assert.Nil(t, doc.Paths["/users"].Options)
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+//State is what a Store keeps for each key. What the fields mean is up to the
+//Algorithm, so a Store only needs to save and load them.
+type State struct {
+	Value    float64
+	Previous float64
+	Time     time.Time
+}
+
+//Result is the outcome of taking from a limit
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	//Reset is how long until the limit is fully available again
+	Reset time.Duration
+	//RetryAfter is how long until a rejected request would be allowed
+	RetryAfter time.Duration
+}
+
+//Algorithm decides whether a request is allowed from the state of its key
+type Algorithm interface {
+	//Take updates the state for a request at the time and returns the result
+	Take(state State, now time.Time) (State, Result)
+	//TTL is how long a key's state is needed after its last request
+	TTL() time.Duration
+	//Policy describes the limit for the RateLimit-Policy header, such as 100;w=60
+	Policy() string
+}
+
+type tokenBucket struct {
+	rate  float64
+	burst int
+	per   time.Duration
+}
+
+//TokenBucket allows bursts of up to burst requests, refilling at limit requests
+//per duration. Requests are allowed as long as there's a token left.
+func TokenBucket(limit int, per time.Duration, burst int) Algorithm {
+	if limit <= 0 || per <= 0 || burst <= 0 {
+		panic("ratelimit: limit, duration and burst must be positive")
+	}
+	return &tokenBucket{rate: float64(limit) / float64(per), burst: burst, per: per}
+}
+
+func (tb *tokenBucket) Take(state State, now time.Time) (State, Result) {
+	tokens := float64(tb.burst)
+	if !state.Time.IsZero() {
+		tokens = math.Min(tokens, state.Value+float64(now.Sub(state.Time))*tb.rate)
+	}
+	result := Result{Limit: tb.burst}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) / tb.rate)
+	}
+	result.Remaining = int(tokens)
+	result.Reset = time.Duration((float64(tb.burst) - tokens) / tb.rate)
+	return State{Value: tokens, Time: now}, result
+}
+
+func (tb *tokenBucket) TTL() time.Duration {
+	return time.Duration(float64(tb.burst) / tb.rate)
+}
+
+func (tb *tokenBucket) Policy() string {
+	return strconv.Itoa(tb.burst) + ";w=" + strconv.Itoa(int(math.Ceil(float64(tb.burst)/tb.rate/float64(time.Second))))
+}
+
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+}
+
+//SlidingWindow allows limit requests in any window of the duration. The count of
+//the previous window is weighted by how much of it overlaps the sliding window,
+//so only two counters are kept for each key.
+func SlidingWindow(limit int, window time.Duration) Algorithm {
+	if limit <= 0 || window <= 0 {
+		panic("ratelimit: limit and window must be positive")
+	}
+	return &slidingWindow{limit: limit, window: window}
+}
+
+func (sw *slidingWindow) Take(state State, now time.Time) (State, Result) {
+	start := now.Truncate(sw.window)
+	switch {
+	case state.Time.Equal(start):
+	case state.Time.Add(sw.window).Equal(start):
+		state = State{Previous: state.Value, Time: start}
+	default:
+		state = State{Time: start}
+	}
+	overlap := 1 - float64(now.Sub(start))/float64(sw.window)
+	count := state.Previous*overlap + state.Value
+	result := Result{Limit: sw.limit, Reset: start.Add(sw.window).Sub(now)}
+	if count < float64(sw.limit) {
+		state.Value++
+		count++
+		result.Allowed = true
+	} else if state.Previous > 0 && state.Value < float64(sw.limit) {
+		//wait until enough of the previous window has slid out
+		needed := 1 - (float64(sw.limit)-state.Value)/state.Previous
+		result.RetryAfter = time.Duration(needed*float64(sw.window)) - now.Sub(start)
+	} else {
+		//the current window becomes the previous one, which then has to slide out
+		result.RetryAfter = result.Reset + time.Duration((1-float64(sw.limit)/state.Value)*float64(sw.window))
+	}
+	result.Remaining = int(math.Max(0, math.Ceil(float64(sw.limit)-count)))
+	return state, result
+}
+
+func (sw *slidingWindow) TTL() time.Duration {
+	return 2 * sw.window
+}
+
+func (sw *slidingWindow) Policy() string {
+	return strconv.Itoa(sw.limit) + ";w=" + strconv.Itoa(int(math.Ceil(sw.window.Seconds())))
+}
@@ -0,0 +1,205 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/CoreyKaylor/gonion/problem"
+)
+
+//Key is what requests are limited by, given the request and the pattern of its route
+type Key func(r *http.Request, route string) string
+
+//IP limits requests by the host of the remote address. Behind a proxy, use
+//middleware that sets RemoteAddr from the forwarded headers you trust.
+func IP() Key {
+	return func(r *http.Request, route string) string {
+		return remoteHost(r)
+	}
+}
+
+func remoteHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+//Header limits requests by the value of the request header. Requests without
+//it are limited by IP, so they don't all share one limit.
+func Header(name string) Key {
+	name = http.CanonicalHeaderKey(name)
+	return func(r *http.Request, route string) string {
+		if value := r.Header.Get(name); value != "" {
+			return name + "=" + value
+		}
+		return "ip=" + remoteHost(r)
+	}
+}
+
+//APIKey limits requests by the X-API-Key header, or the bearer token of the
+//Authorization header when there isn't one. Requests without either are
+//limited by IP, so they don't all share one limit.
+func APIKey() Key {
+	return func(r *http.Request, route string) string {
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			return "key=" + key
+		}
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+			return "key=" + token
+		}
+		return "ip=" + remoteHost(r)
+	}
+}
+
+//Route limits requests by the pattern of their route, such as to limit all of
+//the requests to an expensive route together
+func Route() Key {
+	return func(r *http.Request, route string) string {
+		return route
+	}
+}
+
+//Limiter rejects the requests of a key once they're over the limit
+type Limiter struct {
+	algorithm  Algorithm
+	store      Store
+	keys       []Key
+	prefix     string
+	rejected   http.Handler
+	failClosed bool
+	now        func() time.Time
+	policy     string
+}
+
+//Option configures a Limiter
+type Option func(*Limiter)
+
+//By limits requests by the keys combined, which is IP() unless configured otherwise
+func By(keys ...Key) Option {
+	return func(l *Limiter) {
+		l.keys = keys
+	}
+}
+
+//Using keeps the state of the limit in the store, which is a new MemoryStore
+//unless configured otherwise
+func Using(store Store) Option {
+	return func(l *Limiter) {
+		l.store = store
+	}
+}
+
+//Prefix is added to the keys in the store, so limiters sharing a store don't
+//share limits
+func Prefix(prefix string) Option {
+	return func(l *Limiter) {
+		l.prefix = prefix
+	}
+}
+
+//Rejected handles the requests over the limit, after the RateLimit and
+//Retry-After headers are set. The default responds with 429 problem details.
+func Rejected(handler http.Handler) Option {
+	return func(l *Limiter) {
+		l.rejected = handler
+	}
+}
+
+//FailClosed responds with 503 when the store returns an error, instead of
+//allowing the request
+func FailClosed() Option {
+	return func(l *Limiter) {
+		l.failClosed = true
+	}
+}
+
+//New is a factory method for Limiter
+func New(algorithm Algorithm, options ...Option) *Limiter {
+	l := &Limiter{
+		algorithm: algorithm,
+		keys:      []Key{IP()},
+		rejected:  http.HandlerFunc(tooManyRequests),
+		now:       time.Now,
+	}
+	for _, option := range options {
+		option(l)
+	}
+	if l.store == nil {
+		l.store = NewMemoryStore()
+	}
+	l.policy = algorithm.Policy()
+	return l
+}
+
+//ChainLink limits the requests of the rest of the handler chain. Register
+//RouteChainLink instead to limit by Route().
+func (l *Limiter) ChainLink(inner http.Handler) http.Handler {
+	return l.handler("", inner)
+}
+
+//RouteChainLink limits the requests of the rest of the handler chain, knowing
+//the pattern of the route
+func (l *Limiter) RouteChainLink(route *gonion.RouteModel) gonion.ChainLink {
+	return func(inner http.Handler) http.Handler {
+		return l.handler(route.Pattern, inner)
+	}
+}
+
+func (l *Limiter) handler(route string, inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var result Result
+		err := l.store.Update(r.Context(), l.key(r, route), l.algorithm.TTL(), func(state State) State {
+			state, result = l.algorithm.Take(state, l.now())
+			return state
+		})
+		if err != nil {
+			if l.failClosed {
+				problem.New(http.StatusServiceUnavailable).WithDetail("The rate limit couldn't be checked.").Write(rw, r)
+				return
+			}
+			inner.ServeHTTP(rw, r)
+			return
+		}
+		header := rw.Header()
+		header.Set("RateLimit-Policy", l.policy)
+		header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", seconds(result.Reset))
+		if !result.Allowed {
+			header.Set("Retry-After", seconds(result.RetryAfter))
+			l.rejected.ServeHTTP(rw, r)
+			return
+		}
+		inner.ServeHTTP(rw, r)
+	})
+}
+
+func (l *Limiter) key(r *http.Request, route string) string {
+	if len(l.keys) == 1 {
+		return l.prefix + l.keys[0](r, route)
+	}
+	var key strings.Builder
+	key.WriteString(l.prefix)
+	for i, k := range l.keys {
+		if i > 0 {
+			key.WriteByte('|')
+		}
+		key.WriteString(k(r, route))
+	}
+	return key.String()
+}
+
+//seconds rounds up, so clients retrying after the header don't retry too soon
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+func tooManyRequests(rw http.ResponseWriter, r *http.Request) {
+	problem.New(http.StatusTooManyRequests).WithDetail("The rate limit was exceeded.").Write(rw, r)
+}
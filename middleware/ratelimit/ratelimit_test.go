@@ -0,0 +1,215 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/stretchr/testify/assert"
+)
+
+var ok = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+	rw.Write([]byte("ok"))
+})
+
+//fakeStore is a Store like one for a shared backend, which keeps the state it
+//was given so tests can look at it
+type fakeStore struct {
+	sync.Mutex
+	states map[string]State
+	ttls   map[string]time.Duration
+	err    error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{states: make(map[string]State), ttls: make(map[string]time.Duration)}
+}
+
+func (s *fakeStore) Update(ctx context.Context, key string, ttl time.Duration, update func(State) State) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.states[key] = update(s.states[key])
+	s.ttls[key] = ttl
+	return nil
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)}
+}
+
+func serve(handler http.Handler, method string, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/users", nil)
+	r.RemoteAddr = remoteAddr
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+	return recorder
+}
+
+func TestTokenBucket_AllowsBurstsAndRefills(t *testing.T) {
+	c := newClock()
+	l := New(TokenBucket(1, time.Second, 3))
+	l.now = c.Now
+	handler := l.ChainLink(ok)
+	for i := 2; i >= 0; i-- {
+		recorder := serve(handler, "GET", "10.0.0.1:5000")
+		assert.Equal(t, recorder.Code, http.StatusOK)
+		assert.Equal(t, recorder.Header().Get("RateLimit-Remaining"), string(rune('0'+i)))
+	}
+	recorder := serve(handler, "GET", "10.0.0.1:5000")
+	assert.Equal(t, recorder.Code, http.StatusTooManyRequests)
+	assert.Equal(t, recorder.Header().Get("Retry-After"), "1")
+	assert.Equal(t, recorder.Header().Get("RateLimit-Limit"), "3")
+	assert.Equal(t, recorder.Header().Get("RateLimit-Reset"), "3")
+	assert.Equal(t, recorder.Header().Get("RateLimit-Policy"), "3;w=3")
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/problem+json")
+
+	assert.Equal(t, serve(handler, "GET", "10.0.0.2:5000").Code, http.StatusOK)
+	c.now = c.now.Add(time.Second)
+	assert.Equal(t, serve(handler, "GET", "10.0.0.1:5000").Code, http.StatusOK)
+	assert.Equal(t, serve(handler, "GET", "10.0.0.1:5000").Code, http.StatusTooManyRequests)
+}
+
+func TestSlidingWindow_WeighsThePreviousWindow(t *testing.T) {
+	c := newClock()
+	algorithm := SlidingWindow(10, time.Minute)
+	state, result := State{}, Result{}
+	for i := 0; i < 10; i++ {
+		state, result = algorithm.Take(state, c.now)
+		assert.True(t, result.Allowed)
+	}
+	state, result = algorithm.Take(state, c.now.Add(59*time.Second))
+	assert.False(t, result.Allowed)
+	assert.Equal(t, result.RetryAfter, time.Second)
+
+	//a quarter into the next window, three quarters of the previous one still counts
+	state, result = algorithm.Take(state, c.now.Add(75*time.Second))
+	assert.True(t, result.Allowed)
+	assert.Equal(t, result.Remaining, 2)
+	assert.Equal(t, result.Reset, 45*time.Second)
+	for i := 0; i < 2; i++ {
+		state, result = algorithm.Take(state, c.now.Add(75*time.Second))
+		assert.True(t, result.Allowed)
+	}
+	assert.Equal(t, result.Remaining, 0)
+	state, result = algorithm.Take(state, c.now.Add(75*time.Second))
+	assert.False(t, result.Allowed)
+	assert.Equal(t, result.RetryAfter, 3*time.Second)
+
+	_, result = algorithm.Take(state, c.now.Add(3*time.Minute))
+	assert.True(t, result.Allowed)
+	assert.Equal(t, result.Remaining, 9)
+}
+
+func TestLimiter_UsesTheStoreWithPrefixedKeys(t *testing.T) {
+	store := newFakeStore()
+	l := New(SlidingWindow(2, time.Minute), Using(store), Prefix("api:"), By(APIKey(), Route()))
+	g := gonion.New()
+	g.Use().RouteChainLink(l.RouteChainLink)
+	g.Get("/users/:id", ok)
+	handler := g.BuildRoutes()[0].Handler
+	r := httptest.NewRequest("GET", "/users/1", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, store.states["api:key=secret|/users/:id"].Value, float64(1))
+	assert.Equal(t, store.ttls["api:key=secret|/users/:id"], 2*time.Minute)
+}
+
+func TestLimiter_LimitsRequestsWithoutTheKeyByIP(t *testing.T) {
+	for _, key := range []Key{APIKey(), Header("X-Client-Id")} {
+		handler := New(TokenBucket(1, time.Minute, 1), By(key)).ChainLink(ok)
+		assert.Equal(t, serve(handler, "GET", "10.0.0.1:5000").Code, http.StatusOK)
+		assert.Equal(t, serve(handler, "GET", "10.0.0.1:5000").Code, http.StatusTooManyRequests)
+		assert.Equal(t, serve(handler, "GET", "10.0.0.2:5000").Code, http.StatusOK)
+
+		r := httptest.NewRequest("GET", "/users", nil)
+		r.Header.Set("X-API-Key", "ip=10.0.0.3")
+		r.Header.Set("X-Client-Id", "ip=10.0.0.3")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		assert.Equal(t, serve(handler, "GET", "10.0.0.3:5000").Code, http.StatusOK)
+	}
+}
+
+func TestLimiter_FailsOpenUnlessConfiguredOtherwise(t *testing.T) {
+	store := newFakeStore()
+	store.err = errors.New("unavailable")
+	recorder := serve(New(TokenBucket(1, time.Second, 1), Using(store)).ChainLink(ok), "GET", "10.0.0.1:5000")
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Header().Get("RateLimit-Limit"), "")
+
+	recorder = serve(New(TokenBucket(1, time.Second, 1), Using(store), FailClosed()).ChainLink(ok), "GET", "10.0.0.1:5000")
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+}
+
+func TestLimiter_CanBeStricterForWrites(t *testing.T) {
+	reads := New(TokenBucket(100, time.Minute, 100))
+	writes := New(TokenBucket(1, time.Minute, 1), Rejected(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusTooManyRequests)
+		rw.Write([]byte("slow down"))
+	})))
+	g := gonion.New()
+	g.Sub("/api", func(api *gonion.Composer) {
+		api.Use().ChainLink(reads.ChainLink)
+		api.Only().Post().Use().ChainLink(writes.ChainLink)
+		api.Get("/users", ok)
+		api.Post("/users", ok)
+	})
+	g.Get("/health", ok)
+	routes := g.BuildRoutes()
+	handlers := make(map[string]http.Handler)
+	for _, route := range routes {
+		handlers[route.Method+" "+route.Pattern] = route.Handler
+	}
+	assert.Equal(t, serve(handlers["POST /api/users"], "POST", "10.0.0.1:5000").Code, http.StatusOK)
+	recorder := serve(handlers["POST /api/users"], "POST", "10.0.0.1:5000")
+	assert.Equal(t, recorder.Code, http.StatusTooManyRequests)
+	assert.Equal(t, recorder.Body.String(), "slow down")
+	assert.Equal(t, serve(handlers["GET /api/users"], "GET", "10.0.0.1:5000").Code, http.StatusOK)
+	assert.Equal(t, serve(handlers["GET /health"], "GET", "10.0.0.1:5000").Header().Get("RateLimit-Limit"), "")
+}
+
+func TestMemoryStore_EvictsExpiredKeys(t *testing.T) {
+	c := newClock()
+	store := NewMemoryStore()
+	store.now = c.Now
+	increment := func(state State) State {
+		state.Value++
+		return state
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		store.Update(context.Background(), key, time.Minute, increment)
+	}
+	assert.Equal(t, store.Len(), 3)
+	var value float64
+	c.now = c.now.Add(2 * time.Minute)
+	store.Update(context.Background(), "a", time.Minute, func(state State) State {
+		value = state.Value
+		return increment(state)
+	})
+	assert.Equal(t, value, float64(0))
+
+	for i := 0; i < 200; i++ {
+		store.Update(context.Background(), string(rune('d'+i)), time.Minute, increment)
+	}
+	c.now = c.now.Add(2 * time.Minute)
+	store.Update(context.Background(), "z", time.Minute, increment)
+	for i := 0; i < 1000; i++ {
+		store.Update(context.Background(), string(rune(1000+i)), time.Minute, increment)
+	}
+	assert.Equal(t, store.Len(), 1001)
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+//Store keeps the State of each key. Stores shared by several servers, such as
+//one backed by Redis, must run update atomically for a key, such as by retrying
+//when the state changed in between loading and saving it.
+type Store interface {
+	//Update saves the state update returns for the key's current state, which is
+	//the zero State for new keys. The state can be forgotten after the ttl.
+	Update(ctx context.Context, key string, ttl time.Duration, update func(State) State) error
+}
+
+const shardCount = 32
+
+//MemoryStore is a Store for a single server, sharded by key so requests for
+//different keys rarely wait on each other. Keys are evicted a while after their
+//ttl, when their shard is next updated.
+type MemoryStore struct {
+	seed   maphash.Seed
+	shards [shardCount]shard
+	now    func() time.Time
+}
+
+type shard struct {
+	sync.Mutex
+	entries map[string]*entry
+	sweep   time.Time
+}
+
+type entry struct {
+	state   State
+	expires time.Time
+}
+
+//NewMemoryStore is a factory method for MemoryStore
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{seed: maphash.MakeSeed(), now: time.Now}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*entry)
+	}
+	return s
+}
+
+//Update implements Store
+func (s *MemoryStore) Update(ctx context.Context, key string, ttl time.Duration, update func(State) State) error {
+	now := s.now()
+	sh := &s.shards[maphash.String(s.seed, key)%shardCount]
+	sh.Lock()
+	defer sh.Unlock()
+	if now.After(sh.sweep) {
+		sh.evict(now)
+		sh.sweep = now.Add(ttl)
+	}
+	e, ok := sh.entries[key]
+	if !ok || now.After(e.expires) {
+		e = &entry{}
+		sh.entries[key] = e
+	}
+	e.state = update(e.state)
+	e.expires = now.Add(ttl)
+	return nil
+}
+
+//Len is the number of keys the store has state for
+func (s *MemoryStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.Lock()
+		n += len(sh.entries)
+		sh.Unlock()
+	}
+	return n
+}
+
+func (sh *shard) evict(now time.Time) {
+	for key, e := range sh.entries {
+		if now.After(e.expires) {
+			delete(sh.entries, key)
+		}
+	}
+}
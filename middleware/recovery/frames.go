@@ -0,0 +1,164 @@
+package recovery
+
+import (
+	"bytes"
+	"hash/fnv"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+//The kinds of frames, by where the function is from
+const (
+	App    = "app"
+	Stdlib = "stdlib"
+	Gonion = "gonion"
+)
+
+const gonionPath = "github.com/CoreyKaylor/gonion"
+
+//Frame is a function call on the stack of a panic
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Kind     string `json:"kind"`
+}
+
+//SourceFrame is a frame along with the lines of source around it, which are only
+//read for application frames with their file available locally.
+type SourceFrame struct {
+	Frame
+	Source []SourceLine
+}
+
+//SourceLine is a line of source, Current is the line of the frame
+type SourceLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+//modules are the paths of the modules built into the program, so the packages
+//of a module with a path like myapp aren't taken for the standard library
+var modules = func() []string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	paths := []string{info.Main.Path}
+	for _, dep := range info.Deps {
+		paths = append(paths, dep.Path)
+	}
+	return paths
+}()
+
+//kindOf classifies the function by its package path. Standard library packages
+//don't have a dot in the first element of their path and aren't in a module.
+func kindOf(function string) string {
+	if function == gonionPath || strings.HasPrefix(function, gonionPath+"/") || strings.HasPrefix(function, gonionPath+".") {
+		return Gonion
+	}
+	path := function
+	if slash := strings.LastIndex(path, "/"); slash >= 0 {
+		path = path[:slash]
+	} else if dot := strings.Index(path, "."); dot >= 0 {
+		path = path[:dot]
+	}
+	first := strings.SplitN(path, "/", 2)[0]
+	if path == "main" || strings.Contains(first, ".") || inModule(path) {
+		return App
+	}
+	return Stdlib
+}
+
+func inModule(path string) bool {
+	for _, module := range modules {
+		if module != "" && (path == module || strings.HasPrefix(path, module+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+//withSource reads the source around the application frames
+func withSource(frames []Frame, context int) []SourceFrame {
+	files := make(map[string][][]byte)
+	sourceFrames := make([]SourceFrame, len(frames))
+	for i, frame := range frames {
+		sourceFrames[i].Frame = frame
+		if frame.Kind != App || frame.File == "" {
+			continue
+		}
+		lines, ok := files[frame.File]
+		if !ok {
+			if data, err := os.ReadFile(frame.File); err == nil {
+				lines = bytes.Split(data, []byte("\n"))
+			}
+			files[frame.File] = lines
+		}
+		for number := frame.Line - context; number <= frame.Line+context; number++ {
+			if number < 1 || number > len(lines) {
+				continue
+			}
+			sourceFrames[i].Source = append(sourceFrames[i].Source, SourceLine{
+				Number:  number,
+				Text:    string(lines[number-1]),
+				Current: number == frame.Line,
+			})
+		}
+	}
+	return sourceFrames
+}
+
+//callers returns the program counters of the stack, which are the stack that
+//panicked when it's called while panicking
+func callers() []uintptr {
+	pcs := make([]uintptr, 64)
+	return pcs[:runtime.Callers(2, pcs)]
+}
+
+//panicFrames returns the frames of the stack that panicked, starting at the call
+//that panicked
+func panicFrames(pcs []uintptr) []Frame {
+	callers := runtime.CallersFrames(pcs)
+	var frames []Frame
+	for {
+		frame, more := callers.Next()
+		frames = append(frames, Frame{Function: frame.Function, File: frame.File, Line: frame.Line, Kind: kindOf(frame.Function)})
+		if frame.Function == "runtime.gopanic" {
+			frames = frames[:0]
+		}
+		if !more {
+			return frames
+		}
+	}
+}
+
+//stackKey identifies the stack of the panic, without the goroutine and argument
+//values that make the text of the stack differ between panics.
+func stackKey(frames []Frame) uint64 {
+	hash := fnv.New64a()
+	for _, frame := range frames {
+		hash.Write([]byte(frame.Function))
+		hash.Write([]byte(strconv.Itoa(frame.Line)))
+	}
+	return hash.Sum64()
+}
+
+//framesExtension is the frames as a problem details extension, which can be
+//rendered as either JSON or XML.
+func framesExtension(frames []Frame) []interface{} {
+	extension := make([]interface{}, len(frames))
+	for i, frame := range frames {
+		extension[i] = map[string]interface{}{
+			"function": frame.Function,
+			"file":     frame.File,
+			"line":     frame.Line,
+			"kind":     frame.Kind,
+		}
+	}
+	return extension
+}
@@ -0,0 +1,154 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+type stateKey struct{}
+
+//requestState is how goroutines started for a request find the configuration
+//of the recovery middleware handling it.
+type requestState struct {
+	config     *config
+	route      string
+	goroutines sync.WaitGroup
+}
+
+var defaultState = &requestState{config: func() *config {
+	c := newConfig(nil)
+	return &c
+}()}
+
+func stateFrom(r *http.Request) *requestState {
+	if state, ok := r.Context().Value(stateKey{}).(*requestState); ok {
+		return state
+	}
+	return defaultState
+}
+
+//WaitForGoroutines waits for the goroutines started with Go for a request to
+//finish before the request's handler returns.
+func WaitForGoroutines() Option {
+	return func(c *config) {
+		c.wait = true
+	}
+}
+
+//Go runs fn in a goroutine for the request, recovering from its panics and
+//reporting them the way the recovery middleware handling the request does,
+//or to slog.Default() without one. The request's context is canceled once
+//the handler returns, unless the middleware waits for the goroutines.
+func Go(r *http.Request, fn func()) {
+	state := stateFrom(r)
+	state.goroutines.Add(1)
+	go func() {
+		defer state.goroutines.Done()
+		state.protect(r, fn)
+	}()
+}
+
+//protect calls fn, recovering from and reporting its panic
+func (state *requestState) protect(r *http.Request, fn func()) (event *Event) {
+	start := time.Now()
+	defer func() {
+		if err := recover(); err != nil {
+			event = state.config.newEvent(err, state.route, r, start)
+			event.Goroutine = true
+			state.config.report(r.Context(), event, stackKey(event.Frames))
+		}
+	}()
+	fn()
+	return nil
+}
+
+//Panic is a panic recovered on a goroutine doing the work of a request along with
+//its stack, for middleware that panics with it again on the request's goroutine,
+//like timeout does. The recovery middleware reports the Value with the stack of
+//the goroutine that panicked rather than the one it was panicked again on.
+type Panic struct {
+	Value   interface{}
+	Stack   []byte
+	callers []uintptr
+}
+
+//Capture describes the value recovered from a panic. Call it from the deferred
+//function that recovered it, so the stack is the one that panicked.
+func Capture(value interface{}) *Panic {
+	if p, ok := value.(*Panic); ok {
+		return p
+	}
+	return &Panic{Value: value, Stack: debug.Stack(), callers: callers()}
+}
+
+func (p *Panic) Error() string {
+	return fmt.Sprint(p.Value)
+}
+
+//Report reports a panic captured on a goroutine for the request the way the
+//recovery middleware handling the request does, or to slog.Default() without
+//one. It's for panics nothing else will see, such as a handler's that panicked
+//after its request timed out.
+func Report(r *http.Request, p *Panic) {
+	state := stateFrom(r)
+	event := state.config.newEvent(p, state.route, r, time.Now())
+	event.Goroutine = true
+	state.config.report(r.Context(), event, stackKey(event.Frames))
+}
+
+//PanicError is the error of a goroutine in a Group that panicked
+type PanicError struct {
+	Event *Event
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("recovery: goroutine panicked: %v", err.Event.Value)
+}
+
+//Group is a group of goroutines doing work for a request. Their panics are
+//recovered from and reported like those of Go, and cancel the group's context.
+type Group struct {
+	r          *http.Request
+	ctx        context.Context
+	cancel     context.CancelFunc
+	goroutines sync.WaitGroup
+	once       sync.Once
+	err        error
+}
+
+//NewGroup is a factory method for Group. Its context is canceled along with
+//the request's context, when a goroutine panics or when Wait returns.
+func NewGroup(r *http.Request) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(r.Context())
+	return &Group{r: r, ctx: ctx, cancel: cancel}, ctx
+}
+
+//Go runs fn in a goroutine of the group with the group's context
+func (g *Group) Go(fn func(ctx context.Context)) {
+	state := stateFrom(g.r)
+	g.goroutines.Add(1)
+	go func() {
+		defer g.goroutines.Done()
+		event := state.protect(g.r, func() {
+			fn(g.ctx)
+		})
+		if event != nil {
+			g.once.Do(func() {
+				g.err = &PanicError{Event: event}
+				g.cancel()
+			})
+		}
+	}()
+}
+
+//Wait waits for the goroutines of the group and returns a *PanicError for the
+//first one that panicked.
+func (g *Group) Wait() error {
+	g.goroutines.Wait()
+	g.cancel()
+	return g.err
+}
@@ -0,0 +1,100 @@
+package recovery
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+//go:embed recovery.html
+var stackTraceHTML string
+
+var stackTraceTemplate = template.Must(template.New("recovery.html").Parse(stackTraceHTML))
+
+//Page is what error pages are rendered with. ErrorMessage, StackTrace, Frames
+//and Request are only set by StackTraceRecovery, so production pages don't
+//reveal them.
+type Page struct {
+	Status       int
+	Title        string
+	ErrorMessage interface{}
+	StackTrace   string
+	Frames       []SourceFrame
+	Request      *Request
+}
+
+//Request describes the request that panicked
+type Request struct {
+	Method  string
+	URL     string
+	Route   string
+	Headers http.Header
+	Form    url.Values
+}
+
+//newRequest describes the request, parsing its form when the handler didn't
+func newRequest(r *http.Request, route string) *Request {
+	request := &Request{
+		Method:  r.Method,
+		Route:   route,
+		Headers: r.Header,
+	}
+	if r.URL != nil {
+		request.URL = r.URL.String()
+		if r.Form == nil {
+			r.ParseForm()
+		}
+	}
+	request.Form = r.Form
+	return request
+}
+
+func newPage(status int) *Page {
+	return &Page{
+		Status: status,
+		Title:  http.StatusText(status),
+	}
+}
+
+//Template replaces the stack trace page StackTraceRecovery renders, which is
+//executed with a *Page.
+func Template(t *template.Template) Option {
+	return func(c *config) {
+		c.template = t
+	}
+}
+
+//ErrorPage renders the template, executed with a *Page, for panics answered
+//with the status. It takes the place of the plain text response of SimpleRecovery
+//and the stack trace page of StackTraceRecovery.
+func ErrorPage(status int, t *template.Template) Option {
+	return func(c *config) {
+		c.pages[status] = t
+	}
+}
+
+//renderPage executes the template before writing anything, so a template that
+//fails doesn't leave a partial page.
+func renderPage(rw http.ResponseWriter, t *template.Template, page *Page) {
+	var buffer bytes.Buffer
+	if err := t.Execute(&buffer, page); err != nil {
+		writeText(rw, page)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(page.Status)
+	buffer.WriteTo(rw)
+}
+
+//writeText writes the page as plain text, with the stack trace when it's set
+func writeText(rw http.ResponseWriter, page *Page) {
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(page.Status)
+	rw.Write([]byte(page.Title))
+	if page.StackTrace != "" {
+		fmt.Fprintf(rw, "\n\npanic: %v\n\n%s", page.ErrorMessage, page.StackTrace)
+	}
+}
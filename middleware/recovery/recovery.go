@@ -1,90 +1,190 @@
 package recovery
 
 import (
+	"fmt"
 	"html/template"
 	"net/http"
-	"path"
-	"runtime"
-	"runtime/debug"
-	"strings"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/CoreyKaylor/gonion/negotiation"
+	"github.com/CoreyKaylor/gonion/problem"
 )
 
 //SimpleRecovery is a an http.Handler that only reports a 500 status code
-//without rendering the stacktrace.
+//without rendering the stacktrace. The response is plain text, unless the client
+//prefers a problem details document or there's an error page for the status.
 type SimpleRecovery struct {
+	config
 }
 
 //StackTraceRecovery is intended for development and renders
-//a stacktrace of where the panic occurred.
+//a stacktrace of where the panic occurred. Clients asking for JSON or XML
+//receive a problem details document with the panic value as the detail and the
+//stack as its frames, clients asking for plain text receive the stack as text.
 type StackTraceRecovery struct {
+	config
+}
+
+//Option configures how panics are reported and rendered
+type Option func(*config)
+
+type config struct {
+	reporting
 	template *template.Template
+	pages    map[int]*template.Template
+	statuses []func(interface{}) int
+	wait     bool
+}
+
+//Status maps panic values to the status they're answered with, such as a 503 for
+//a panic with a particular error, by returning 0 for the values it doesn't map.
+//The first status option that maps a value is used, values that aren't mapped are
+//answered with a 500.
+func Status(status func(value interface{}) int) Option {
+	return func(c *config) {
+		c.statuses = append(c.statuses, status)
+	}
+}
+
+func (c *config) statusFor(value interface{}) int {
+	for _, status := range c.statuses {
+		if code := status(value); code != 0 {
+			return code
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+func newConfig(options []Option) config {
+	c := config{
+		reporting: reporting{requestIDHeader: "X-Request-Id", reporter: slogReporter{}},
+		template:  stackTraceTemplate,
+		pages:     make(map[int]*template.Template),
+	}
+	for _, option := range options {
+		option(&c)
+	}
+	return c
 }
 
-//Recovery is a factory method for SimpleRecovery
+//Recovery is a factory method for SimpleRecovery that logs panics to slog.Default()
 func Recovery(inner http.Handler) http.Handler {
-	recovery := &SimpleRecovery{}
-	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		recovery.ServeHTTP(rw, r, inner)
-	})
+	return New().ChainLink(inner)
 }
 
-//WithStackTrace is a factory method for RecoveryWithStackTrace
+//WithStackTrace is a factory method for StackTraceRecovery that logs panics to slog.Default()
 func WithStackTrace(inner http.Handler) http.Handler {
-	recovery := &StackTraceRecovery{
-		template: createErrorTemplate(),
+	return NewStackTrace().ChainLink(inner)
+}
+
+//New is a factory method for SimpleRecovery with options for reporting panics
+func New(options ...Option) *SimpleRecovery {
+	return &SimpleRecovery{
+		config: newConfig(options),
 	}
+}
+
+//NewStackTrace is a factory method for StackTraceRecovery with options for reporting panics
+func NewStackTrace(options ...Option) *StackTraceRecovery {
+	return &StackTraceRecovery{
+		config: newConfig(options),
+	}
+}
+
+//ChainLink wraps the rest of the handler chain
+func (recovery *SimpleRecovery) ChainLink(inner http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		recovery.ServeHTTP(rw, r, inner)
 	})
 }
 
-func createErrorTemplate() *template.Template {
-	filename := getCurrentFile()
-	dir := path.Dir(filename)
-	recoveryFile := path.Join(dir, "recovery.html")
-	return template.Must(template.ParseFiles(recoveryFile))
+//RouteChainLink wraps the rest of the handler chain and reports panics with the
+//route's pattern.
+func (recovery *SimpleRecovery) RouteChainLink(route *gonion.RouteModel) gonion.ChainLink {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			recovery.serve(route.Pattern, rw, r, inner)
+		})
+	}
 }
 
-func getCurrentFile() string {
-	_, filename, _, _ := runtime.Caller(1)
-	//hack: not sure why Caller is inconsistent between test and run
-	if strings.Contains(filename, "recovery/_test") {
-		_, filename, _, _ = runtime.Caller(3)
-	}
-	return filename
+//ChainLink wraps the rest of the handler chain
+func (recovery *StackTraceRecovery) ChainLink(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		recovery.ServeHTTP(rw, r, inner)
+	})
 }
 
-type panicError struct {
-	ErrorMessage interface{}
-	StackTrace   string
+//RouteChainLink wraps the rest of the handler chain and reports panics with the
+//route's pattern.
+func (recovery *StackTraceRecovery) RouteChainLink(route *gonion.RouteModel) gonion.ChainLink {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			recovery.serve(route.Pattern, rw, r, inner)
+		})
+	}
 }
 
 //ServeHTTP is the implementation of the standard http.Handler interface
 //that will render a stacktrace.
 func (recovery *StackTraceRecovery) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.Handler) {
-	handlePanic(func(err interface{}) {
-		stack := debug.Stack()
-		rw.WriteHeader(http.StatusInternalServerError)
-		rw.Header().Set("Content-Type", "text/html")
-		pe := &panicError{err, string(stack)}
-		recovery.template.Execute(rw, pe)
+	recovery.serve("", rw, r, next)
+}
+
+func (recovery *StackTraceRecovery) serve(route string, rw http.ResponseWriter, r *http.Request, next http.Handler) {
+	recovery.handlePanic(route, func(event *Event) {
+		recovery.respond(rw, r, event, true)
 	}, next, rw, r)
 }
 
 //ServeHTTP is the implementation of the standard http.Handler interface
-//that will only report a Internal Server Error
+//that will only report a Internal Server Error. Clients asking for JSON or XML
+//receive it as a problem details document.
 func (recovery *SimpleRecovery) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.Handler) {
-	handlePanic(func(err interface{}) {
-		rw.WriteHeader(http.StatusInternalServerError)
-		rw.Write([]byte("Internal Server Error"))
+	recovery.serve("", rw, r, next)
+}
+
+func (recovery *SimpleRecovery) serve(route string, rw http.ResponseWriter, r *http.Request, next http.Handler) {
+	recovery.handlePanic(route, func(event *Event) {
+		recovery.respond(rw, r, event, false)
 	}, next, rw, r)
 }
 
-func handlePanic(afterPanicFunc func(interface{}), next http.Handler, rw http.ResponseWriter, r *http.Request) {
-	defer func() {
-		if err := recover(); err != nil {
-			afterPanicFunc(err)
+//respond negotiates between an HTML page, a problem details document and plain
+//text, preferring the page when there is one. The stack is only included in development.
+func (c *config) respond(rw http.ResponseWriter, r *http.Request, event *Event, development bool) {
+	page := newPage(event.Status)
+	t, html := c.pages[page.Status]
+	if development {
+		page.ErrorMessage = event.Value
+		page.StackTrace = string(event.Stack)
+		if !html {
+			t, html = c.template, true
 		}
-	}()
-	next.ServeHTTP(rw, r)
+	}
+	offers := []string{"text/plain", "text/html"}
+	if html {
+		offers = []string{"text/html", "text/plain"}
+	}
+	offers = append(offers, problem.ContentTypeJSON, "application/json", problem.ContentTypeXML, "application/xml")
+	switch negotiation.Negotiate(r, offers...) {
+	case "text/html":
+		if html {
+			if development {
+				page.Frames = withSource(event.Frames, 3)
+				page.Request = newRequest(r, event.Route)
+			}
+			renderPage(rw, t, page)
+			return
+		}
+	case "text/plain", "":
+	default:
+		details := problem.New(page.Status)
+		if development {
+			details.WithDetail(fmt.Sprint(event.Value)).With("frames", framesExtension(event.Frames))
+		}
+		details.Write(rw, r)
+		return
+	}
+	writeText(rw, page)
 }
@@ -1,11 +1,21 @@
 package recovery
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/CoreyKaylor/gonion"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,6 +34,7 @@ func TestRecoveryWithStackTrace_WithPanic(t *testing.T) {
 	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
 	assert.True(t, strings.Contains(body, "class=\"stacktrace\""))
 	assert.True(t, strings.Contains(body, "oh no!"))
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "text/html; charset=utf-8")
 }
 
 func TestRecoveryWithStackTrace_WithoutPanic(t *testing.T) {
@@ -51,3 +62,320 @@ func TestStandardRecovery_WithoutPanic(t *testing.T) {
 	assert.Equal(t, recorder.Code, http.StatusOK)
 	assert.False(t, strings.Contains(recorder.Body.String(), "class=\"stacktrace\""))
 }
+
+func TestStandardRecovery_WithPanicRendersProblemForJSONClients(t *testing.T) {
+	recovery := Recovery(panicHandler)
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Set("Accept", "application/json")
+	recovery.ServeHTTP(recorder, request)
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/problem+json")
+	assert.Equal(t, recorder.Body.String(), `{"type":"about:blank","title":"Internal Server Error","status":500}`)
+}
+
+func TestRecovery_ReportsPanicsWithTheRoute(t *testing.T) {
+	var events []*Event
+	recovery := New(Reporting(ReporterFunc(func(ctx context.Context, event *Event) {
+		events = append(events, event)
+	})))
+	g := gonion.New()
+	g.Use().RouteChainLink(recovery.RouteChainLink)
+	g.Get("/users/:id", panicHandler)
+	routes := g.BuildRoutes()
+	request := httptest.NewRequest("GET", "/users/1", nil)
+	request.Header.Set("X-Request-Id", "abc")
+	recorder := httptest.NewRecorder()
+	routes[0].Handler.ServeHTTP(recorder, request)
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	assert.Equal(t, len(events), 1)
+	event := events[0]
+	assert.Equal(t, event.Value, "oh no!")
+	assert.Equal(t, event.Route, "/users/:id")
+	assert.Equal(t, event.Method, "GET")
+	assert.Equal(t, event.Path, "/users/1")
+	assert.Equal(t, event.RequestID, "abc")
+	assert.True(t, strings.Contains(string(event.Stack), "panic"))
+}
+
+func TestRecovery_LogsPanicsToSlog(t *testing.T) {
+	var buffer bytes.Buffer
+	recovery := New(Logger(slog.New(slog.NewJSONHandler(&buffer, nil))), RequestIDHeader("X-Trace"))
+	request := httptest.NewRequest("POST", "/orders", nil)
+	request.Header.Set("X-Trace", "xyz")
+	recovery.ChainLink(panicHandler).ServeHTTP(httptest.NewRecorder(), request)
+	var record map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &record))
+	assert.Equal(t, record["level"], "ERROR")
+	assert.Equal(t, record["msg"], "recovered from panic")
+	assert.Equal(t, record["panic"], "oh no!")
+	assert.Equal(t, record["method"], "POST")
+	assert.Equal(t, record["path"], "/orders")
+	assert.Equal(t, record["request_id"], "xyz")
+	assert.NotEmpty(t, record["stack"])
+}
+
+func TestRecovery_RateLimitsDuplicateStacks(t *testing.T) {
+	var suppressed []int
+	recovery := New(RateLimit(time.Hour), Reporting(ReporterFunc(func(ctx context.Context, event *Event) {
+		suppressed = append(suppressed, event.Suppressed)
+	})))
+	handler := recovery.ChainLink(panicHandler)
+	for i := 0; i < 3; i++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, new(http.Request))
+		assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	}
+	assert.Equal(t, suppressed, []int{0})
+
+	l := &limiter{window: time.Minute, seen: make(map[uint64]*seen)}
+	now := time.Now()
+	l.allow(1, now)
+	l.allow(1, now.Add(time.Second))
+	count, ok := l.allow(1, now.Add(2*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, count, 1)
+}
+
+func TestRecovery_RendersCustomPages(t *testing.T) {
+	dev := template.Must(template.New("dev").Parse(`<h1>{{.Status}} {{.ErrorMessage}}</h1>`))
+	recorder := httptest.NewRecorder()
+	NewStackTrace(Template(dev)).ChainLink(panicHandler).ServeHTTP(recorder, new(http.Request))
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	assert.Equal(t, recorder.Body.String(), "<h1>500 oh no!</h1>")
+
+	production := template.Must(template.New("production").Parse(`<h1>{{.Title}}{{.ErrorMessage}}</h1>`))
+	recorder = httptest.NewRecorder()
+	New(ErrorPage(http.StatusInternalServerError, production)).ChainLink(panicHandler).ServeHTTP(recorder, new(http.Request))
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "text/html; charset=utf-8")
+	assert.Equal(t, recorder.Body.String(), "<h1>Internal Server Error</h1>")
+}
+
+func TestRecovery_FallsBackToTextWhenThePageFails(t *testing.T) {
+	failing := template.Must(template.New("failing").Parse(`<h1>{{.Missing}}</h1>`))
+	recorder := httptest.NewRecorder()
+	New(ErrorPage(http.StatusInternalServerError, failing)).ChainLink(panicHandler).ServeHTTP(recorder, new(http.Request))
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	assert.Equal(t, recorder.Body.String(), "Internal Server Error")
+}
+
+var errUnavailable = errors.New("unavailable")
+
+func serveWithAccept(handler http.Handler, accept string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set("Accept", accept)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRecoveryWithStackTrace_NegotiatesTheResponse(t *testing.T) {
+	recovery := WithStackTrace(panicHandler)
+	recorder := serveWithAccept(recovery, "application/json")
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/problem+json")
+	var details struct {
+		Detail string  `json:"detail"`
+		Frames []Frame `json:"frames"`
+	}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &details))
+	assert.Equal(t, details.Detail, "oh no!")
+	assert.True(t, strings.Contains(details.Frames[0].Function, "recovery"))
+	assert.True(t, strings.HasSuffix(details.Frames[0].File, "recovery_test.go"))
+
+	recorder = serveWithAccept(recovery, "text/plain")
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.True(t, strings.HasPrefix(recorder.Body.String(), "Internal Server Error\n\npanic: oh no!\n\ngoroutine"))
+
+	recorder = serveWithAccept(recovery, "text/html,application/xhtml+xml,*/*;q=0.8")
+	assert.True(t, strings.Contains(recorder.Body.String(), "class=\"stacktrace\""))
+}
+
+func TestRecovery_NegotiatesWithoutRevealingThePanic(t *testing.T) {
+	recovery := Recovery(panicHandler)
+	recorder := serveWithAccept(recovery, "text/html,application/xhtml+xml,*/*;q=0.8")
+	assert.Equal(t, recorder.Body.String(), "Internal Server Error")
+	recorder = serveWithAccept(recovery, "application/xml")
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/problem+xml")
+	assert.False(t, strings.Contains(recorder.Body.String(), "oh no!"))
+}
+
+func TestRecovery_MapsPanicValuesToStatuses(t *testing.T) {
+	var status int
+	recovery := New(
+		Status(func(value interface{}) int {
+			if err, ok := value.(error); ok && errors.Is(err, errUnavailable) {
+				return http.StatusServiceUnavailable
+			}
+			return 0
+		}),
+		Reporting(ReporterFunc(func(ctx context.Context, event *Event) {
+			status = event.Status
+		})),
+	)
+	handler := recovery.ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic(errUnavailable)
+	}))
+	recorder := serveWithAccept(handler, "application/json")
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, status, http.StatusServiceUnavailable)
+	assert.Equal(t, recorder.Body.String(), `{"type":"about:blank","title":"Service Unavailable","status":503}`)
+
+	recorder = serveWithAccept(recovery.ChainLink(panicHandler), "text/plain")
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+}
+
+func TestRecoveryWithStackTrace_RendersFramesAndRequest(t *testing.T) {
+	g := gonion.New()
+	g.Use().RouteChainLink(NewStackTrace().RouteChainLink)
+	g.Get("/users/:id", panicHandler)
+	request := httptest.NewRequest("GET", "/users/1?tab=orders", nil)
+	request.Header.Set("X-Request-Id", "abc")
+	recorder := httptest.NewRecorder()
+	g.BuildRoutes()[0].Handler.ServeHTTP(recorder, request)
+	body := recorder.Body.String()
+	assert.True(t, strings.Contains(body, "GET /users/1?tab=orders"))
+	assert.True(t, strings.Contains(body, "<td>/users/:id</td>"))
+	assert.True(t, strings.Contains(body, "<th>X-Request-Id</th><td>abc </td>"))
+	assert.True(t, strings.Contains(body, "<th>Form: tab</th><td>orders </td>"))
+	assert.True(t, strings.Contains(body, `<div class="frame gonion">`))
+	assert.True(t, strings.Contains(body, `<div class="frame stdlib">`))
+}
+
+func TestFrames_AreClassified(t *testing.T) {
+	assert.Equal(t, kindOf("net/http.HandlerFunc.ServeHTTP"), Stdlib)
+	assert.Equal(t, kindOf("runtime.goexit"), Stdlib)
+	assert.Equal(t, kindOf("github.com/CoreyKaylor/gonion.(*Composer).Build"), Gonion)
+	assert.Equal(t, kindOf("github.com/CoreyKaylor/gonion/middleware/recovery.(*config).handlePanic.func1"), Gonion)
+	assert.Equal(t, kindOf("github.com/CoreyKaylor/gonionapp/users.Show"), App)
+	assert.Equal(t, kindOf("example.com/app/users.(*Handler).ServeHTTP"), App)
+	assert.Equal(t, kindOf("main.main.func1"), App)
+}
+
+func TestFrames_AreClassifiedForModulesWithoutADot(t *testing.T) {
+	defer func(previous []string) {
+		modules = previous
+	}(modules)
+	modules = []string{"myapp", "tools"}
+	assert.Equal(t, kindOf("myapp/internal/users.Show"), App)
+	assert.Equal(t, kindOf("myapp.Handler.ServeHTTP"), App)
+	assert.Equal(t, kindOf("tools/export.(*Writer).Write"), App)
+	assert.Equal(t, kindOf("myapplication/users.Show"), Stdlib)
+	assert.Equal(t, kindOf("net/http.HandlerFunc.ServeHTTP"), Stdlib)
+}
+
+func TestFrames_IncludeSourceOfApplicationFrames(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "users.go")
+	os.WriteFile(file, []byte("package users\n\nfunc Show() {\n\tpanic(\"oh no!\")\n}\n"), 0644)
+	frames := withSource([]Frame{
+		{Function: "example.com/app/users.Show", File: file, Line: 4, Kind: App},
+		{Function: "net/http.HandlerFunc.ServeHTTP", File: file, Line: 4, Kind: Stdlib},
+	}, 1)
+	assert.Equal(t, frames[0].Source, []SourceLine{
+		{Number: 3, Text: "func Show() {"},
+		{Number: 4, Text: "\tpanic(\"oh no!\")", Current: true},
+		{Number: 5, Text: "}"},
+	})
+	assert.Nil(t, frames[1].Source)
+}
+
+func TestRecovery_AbortsCommittedResponses(t *testing.T) {
+	var events []*Event
+	recovery := NewStackTrace(Reporting(ReporterFunc(func(ctx context.Context, event *Event) {
+		events = append(events, event)
+	})))
+	handler := recovery.ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("partial"))
+		panic("oh no!")
+	}))
+	recorder := httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(recorder, new(http.Request))
+	})
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Body.String(), "partial")
+	assert.Equal(t, len(events), 1)
+	assert.True(t, events[0].Committed)
+}
+
+func TestRecovery_DoesNotCommitForInformationalResponses(t *testing.T) {
+	handler := New().ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusEarlyHints)
+		panic("oh no!")
+	}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, new(http.Request))
+	assert.Equal(t, recorder.Body.String(), "Internal Server Error")
+}
+
+func TestRecovery_PanicsAgainWithErrAbortHandler(t *testing.T) {
+	reported := false
+	recovery := New(Reporting(ReporterFunc(func(ctx context.Context, event *Event) {
+		reported = true
+	})))
+	handler := recovery.ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), new(http.Request))
+	})
+	assert.False(t, reported)
+}
+
+func TestRecovery_KeepsFlushingAvailable(t *testing.T) {
+	handler := New().ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("partial"))
+		assert.Nil(t, http.NewResponseController(rw).Flush())
+	}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, new(http.Request))
+	assert.True(t, recorder.Flushed)
+}
+
+func TestGo_ReportsPanicsLikeTheMiddleware(t *testing.T) {
+	events := make(chan *Event, 1)
+	recovery := New(WaitForGoroutines(), Reporting(ReporterFunc(func(ctx context.Context, event *Event) {
+		events <- event
+	})))
+	g := gonion.New()
+	g.Use().RouteChainLink(recovery.RouteChainLink)
+	g.Get("/users/:id", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		Go(r, func() {
+			panic("in the background")
+		})
+		rw.Write([]byte("ok"))
+	}))
+	recorder := httptest.NewRecorder()
+	g.BuildRoutes()[0].Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/users/1", nil))
+	assert.Equal(t, recorder.Body.String(), "ok")
+	select {
+	case event := <-events:
+		assert.Equal(t, event.Value, "in the background")
+		assert.Equal(t, event.Route, "/users/:id")
+		assert.True(t, event.Goroutine)
+	default:
+		t.Fatal("expected the goroutine's panic to be reported before the handler returned")
+	}
+}
+
+func TestGroup_CancelsItsContextWhenAGoroutinePanics(t *testing.T) {
+	var buffer bytes.Buffer
+	handler := New(Logger(slog.New(slog.NewJSONHandler(&buffer, nil)))).ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		group, ctx := NewGroup(r)
+		group.Go(func(ctx context.Context) {
+			panic("oh no!")
+		})
+		group.Go(func(ctx context.Context) {
+			<-ctx.Done()
+		})
+		err := group.Wait()
+		assert.Equal(t, err.Error(), "recovery: goroutine panicked: oh no!")
+		assert.Equal(t, err.(*PanicError).Event.Value, "oh no!")
+		assert.Equal(t, ctx.Err(), context.Canceled)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+	assert.True(t, strings.Contains(buffer.String(), `"goroutine":true`))
+}
@@ -0,0 +1,222 @@
+package recovery
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/CoreyKaylor/gonion/writer"
+)
+
+//Event describes a recovered panic
+type Event struct {
+	Value     interface{}
+	Status    int
+	Stack     []byte
+	Frames    []Frame
+	Route     string
+	Method    string
+	Path      string
+	RequestID string
+	Duration  time.Duration
+	//Goroutine is whether it panicked in a goroutine started with Go or a Group,
+	//rather than while handling the request.
+	Goroutine bool
+	//Committed is whether the response was committed when it panicked, in which
+	//case the connection was aborted rather than answered.
+	Committed bool
+	//Suppressed is how many panics with the same stack weren't reported since the
+	//last one that was, when rate limited.
+	Suppressed int
+}
+
+//Reporter is notified of the panics recovered, such as to log them or send them
+//to an error tracking service.
+type Reporter interface {
+	Report(context.Context, *Event)
+}
+
+//ReporterFunc is a simple func that represents a Reporter
+type ReporterFunc func(context.Context, *Event)
+
+//Report is the ReporterFunc implementation of the Reporter interface
+func (f ReporterFunc) Report(ctx context.Context, event *Event) {
+	f(ctx, event)
+}
+
+//Logger reports panics as errors to the slog logger, which is slog.Default()
+//unless configured otherwise.
+func Logger(logger *slog.Logger) Option {
+	return Reporting(slogReporter{logger})
+}
+
+//Reporting reports panics to the reporter instead of logging them
+func Reporting(reporter Reporter) Option {
+	return func(c *config) {
+		c.reporter = reporter
+	}
+}
+
+//RequestIDHeader is the request header the request ID is read from, which is
+//X-Request-Id by default.
+func RequestIDHeader(name string) Option {
+	return func(c *config) {
+		c.requestIDHeader = name
+	}
+}
+
+//RateLimit reports a panic with the same stack at most once per window, the
+//next report counts the panics that weren't.
+func RateLimit(window time.Duration) Option {
+	return func(c *config) {
+		c.limiter = &limiter{window: window, seen: make(map[uint64]*seen)}
+	}
+}
+
+type reporting struct {
+	reporter        Reporter
+	requestIDHeader string
+	limiter         *limiter
+}
+
+//handlePanic serves the request and when it panics, reports the panic and
+//calls afterPanicFunc to respond. When the response was already committed, the
+//connection is aborted with http.ErrAbortHandler instead, since responding would
+//only corrupt the partial response. Panics with http.ErrAbortHandler aren't
+//reported and are panicked again as they are.
+func (c *config) handlePanic(route string, afterPanicFunc func(*Event), next http.Handler, rw http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	tracked := writer.Wrap(rw)
+	state := &requestState{config: c, route: route}
+	r = r.WithContext(context.WithValue(r.Context(), stateKey{}, state))
+	defer func() {
+		if err := recover(); err != nil {
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			event := c.newEvent(err, route, r, start)
+			event.Status = c.statusFor(event.Value)
+			event.Committed = tracked.Written()
+			c.report(r.Context(), event, stackKey(event.Frames))
+			if event.Committed {
+				panic(http.ErrAbortHandler)
+			}
+			afterPanicFunc(event)
+		}
+	}()
+	next.ServeHTTP(tracked.ResponseWriter(), r)
+	if c.wait {
+		state.goroutines.Wait()
+	}
+	tracked.Release()
+}
+
+//newEvent describes the panic, it's called while panicking to capture the stack
+//unless the value is a *Panic that was captured where it panicked
+func (c *config) newEvent(value interface{}, route string, r *http.Request, start time.Time) *Event {
+	p := Capture(value)
+	event := &Event{
+		Value:     p.Value,
+		Stack:     p.Stack,
+		Frames:    panicFrames(p.callers),
+		Route:     route,
+		Method:    r.Method,
+		RequestID: r.Header.Get(c.requestIDHeader),
+		Duration:  time.Since(start),
+	}
+	if r.URL != nil {
+		event.Path = r.URL.Path
+	}
+	return event
+}
+
+func (rep *reporting) report(ctx context.Context, event *Event, key uint64) {
+	if rep.limiter != nil {
+		suppressed, ok := rep.limiter.allow(key, time.Now())
+		if !ok {
+			return
+		}
+		event.Suppressed = suppressed
+	}
+	rep.reporter.Report(ctx, event)
+}
+
+type slogReporter struct {
+	logger *slog.Logger
+}
+
+func (s slogReporter) Report(ctx context.Context, event *Event) {
+	logger := s.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attrs := []slog.Attr{
+		slog.Any("panic", event.Value),
+		slog.Int("status", event.Status),
+		slog.String("method", event.Method),
+		slog.String("path", event.Path),
+		slog.Duration("duration", event.Duration),
+		slog.String("stack", string(event.Stack)),
+	}
+	if event.Route != "" {
+		attrs = append(attrs, slog.String("route", event.Route))
+	}
+	if event.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", event.RequestID))
+	}
+	if event.Goroutine {
+		attrs = append(attrs, slog.Bool("goroutine", true))
+	}
+	if event.Committed {
+		attrs = append(attrs, slog.Bool("committed", true))
+	}
+	if event.Suppressed > 0 {
+		attrs = append(attrs, slog.Int("suppressed", event.Suppressed))
+	}
+	logger.LogAttrs(ctx, slog.LevelError, "recovered from panic", attrs...)
+}
+
+type seen struct {
+	reported   time.Time
+	suppressed int
+}
+
+type limiter struct {
+	window time.Duration
+	mu     sync.Mutex
+	seen   map[uint64]*seen
+}
+
+//allow returns whether the stack should be reported and how many times it wasn't
+//since it last was.
+func (l *limiter) allow(key uint64, now time.Time) (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stack, ok := l.seen[key]
+	if ok && now.Sub(stack.reported) < l.window {
+		stack.suppressed++
+		return 0, false
+	}
+	if !ok {
+		if len(l.seen) >= 1024 {
+			l.forget(now)
+		}
+		stack = &seen{}
+		l.seen[key] = stack
+	}
+	suppressed := stack.suppressed
+	stack.reported = now
+	stack.suppressed = 0
+	return suppressed, true
+}
+
+//forget removes the stacks that haven't been reported within the window
+func (l *limiter) forget(now time.Time) {
+	for key, stack := range l.seen {
+		if now.Sub(stack.reported) >= l.window {
+			delete(l.seen, key)
+		}
+	}
+}
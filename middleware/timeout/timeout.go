@@ -0,0 +1,258 @@
+package timeout
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/CoreyKaylor/gonion/middleware/recovery"
+	"github.com/CoreyKaylor/gonion/problem"
+	"github.com/CoreyKaylor/gonion/writer"
+)
+
+//Key is the route metadata key for the time.Duration a route is given by
+//RouteChainLink instead of the default, where zero means no timeout
+const Key = "timeout.duration"
+
+//Timeout gives the rest of the handler chain a deadline to respond by
+type Timeout struct {
+	duration time.Duration
+	status   int
+	response http.Handler
+}
+
+//Option configures a Timeout
+type Option func(*Timeout)
+
+//Status is the status of the response to requests that timed out, which is
+//503 unless configured otherwise
+func Status(status int) Option {
+	return func(t *Timeout) {
+		t.status = status
+	}
+}
+
+//Response writes the response to requests that timed out, instead of problem
+//details with the Status
+func Response(handler http.Handler) Option {
+	return func(t *Timeout) {
+		t.response = handler
+	}
+}
+
+//New is a factory method for Timeout
+func New(duration time.Duration, options ...Option) *Timeout {
+	t := &Timeout{
+		duration: duration,
+		status:   http.StatusServiceUnavailable,
+	}
+	for _, option := range options {
+		option(t)
+	}
+	if t.response == nil {
+		t.response = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			problem.New(t.status).WithDetail("The request timed out.").Write(rw, r)
+		})
+	}
+	return t
+}
+
+//ChainLink gives the rest of the handler chain the duration to respond by
+func (t *Timeout) ChainLink(inner http.Handler) http.Handler {
+	return t.handler(t.duration, inner)
+}
+
+//RouteChainLink gives the rest of the handler chain the duration of the route's
+//Key metadata to respond by, or the default duration when it has none
+func (t *Timeout) RouteChainLink(route *gonion.RouteModel) gonion.ChainLink {
+	duration := t.duration
+	if d, ok := route.Metadata[Key].(time.Duration); ok {
+		duration = d
+	}
+	return func(inner http.Handler) http.Handler {
+		return t.handler(duration, inner)
+	}
+}
+
+//handler runs the rest of the chain in its own goroutine with a deadline on the
+//request's context. When the deadline passes first, the response is written
+//unless the handler already started its own, in which case it's aborted so the
+//client doesn't take the partial response for a complete one. Either way the
+//handler's later writes are dropped, and its later panics are reported with
+//recovery.Report since nothing is waiting for it anymore. Panics in time are
+//panicked again on the request's goroutine as a *recovery.Panic, so the recovery
+//middleware in front of the timeout reports the stack of the handler's goroutine.
+func (t *Timeout) handler(duration time.Duration, inner http.Handler) http.Handler {
+	if duration <= 0 {
+		return inner
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), duration)
+		defer cancel()
+		r = r.WithContext(ctx)
+		//the handler's goroutine traces on its own copy, which is only joined once
+		//it's done, since it can still be running after the request was traced
+		traced, join := gonion.ForkTrace(ctx)
+		tw := &timeoutWriter{rw: rw, header: rw.Header().Clone()}
+		w := writer.Through(rw, tw)
+		done := make(chan struct{})
+		panics := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				p := recover()
+				if p != nil && p != http.ErrAbortHandler {
+					p = recovery.Capture(p)
+				}
+				tw.mu.Lock()
+				timedOut := tw.timedOut
+				if !timedOut && p != nil {
+					panics <- p
+				} else if !timedOut {
+					close(done)
+				}
+				tw.mu.Unlock()
+				if late, ok := p.(*recovery.Panic); ok && timedOut {
+					recovery.Report(r, late)
+				}
+			}()
+			inner.ServeHTTP(w.ResponseWriter(), r.WithContext(traced))
+		}()
+		finish := func(p interface{}) {
+			join()
+			w.Release()
+			if p != nil {
+				panic(p)
+			}
+		}
+		select {
+		case p := <-panics:
+			finish(p)
+		case <-done:
+			finish(nil)
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			select {
+			case p := <-panics:
+				finish(p)
+				return
+			case <-done:
+				finish(nil)
+				return
+			default:
+			}
+			//the handler's goroutine can still use its writer, so it's left to the
+			//garbage collector rather than released
+			tw.timedOut = true
+			if ctx.Err() != context.DeadlineExceeded || tw.hijacked {
+				//there's nobody to respond to, the client went away or the handler
+				//took over the connection
+				return
+			}
+			if tw.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			t.response.ServeHTTP(rw, r)
+		}
+	})
+}
+
+//timeoutWriter guards the ResponseWriter from the handler once the request timed out.
+//The handler gets its own copy of the headers, since it can still be setting them
+//while the response to the timeout is written, which replaces the response's
+//headers when it commits. It's wrapped with writer.Through, so the handler sees the
+//same optional interfaces as the ResponseWriter.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	rw          http.ResponseWriter
+	header      http.Header
+	wroteHeader bool
+	hijacked    bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeader(status)
+}
+
+func (tw *timeoutWriter) writeHeader(status int) {
+	header := tw.rw.Header()
+	for key := range header {
+		if _, ok := tw.header[key]; !ok {
+			delete(header, key)
+		}
+	}
+	for key, values := range tw.header {
+		header[key] = values
+	}
+	tw.rw.WriteHeader(status)
+	if status >= http.StatusOK || status == http.StatusSwitchingProtocols {
+		tw.wroteHeader = true
+	}
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	return tw.rw.Write(p)
+}
+
+//Flush lets handlers stream a response, such as a long running export, until
+//the request times out
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	http.NewResponseController(tw.rw).Flush()
+}
+
+//Hijack hands the connection over to the handler, after which no response is
+//written when the request times out. The request's context is still canceled at
+//the deadline though, so a handler that keeps the connection longer, such as for
+//a websocket, needs a context from context.WithoutCancel or a route without a
+//timeout.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	conn, buffer, err := http.NewResponseController(tw.rw).Hijack()
+	if err == nil {
+		tw.hijacked = true
+	}
+	return conn, buffer, err
+}
+
+func (tw *timeoutWriter) Push(target string, options *http.PushOptions) error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return http.ErrHandlerTimeout
+	}
+	return tw.rw.(http.Pusher).Push(target, options)
+}
@@ -0,0 +1,266 @@
+package timeout
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/CoreyKaylor/gonion/middleware/recovery"
+	"github.com/stretchr/testify/assert"
+)
+
+//slow responds after the delay like a handler that ignores its context
+func slow(delay time.Duration, writes chan error) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		rw.Header().Set("X-Late", "true")
+		_, err := rw.Write([]byte("done"))
+		if writes != nil {
+			writes <- err
+		}
+	})
+}
+
+func TestTimeout_RespondsWhenTheDeadlinePasses(t *testing.T) {
+	writes := make(chan error, 1)
+	recorder := httptest.NewRecorder()
+	New(10*time.Millisecond).ChainLink(slow(200*time.Millisecond, writes)).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/problem+json")
+	assert.Contains(t, recorder.Body.String(), "The request timed out.")
+	assert.Equal(t, <-writes, http.ErrHandlerTimeout)
+	assert.Equal(t, recorder.Header().Get("X-Late"), "")
+	assert.NotContains(t, recorder.Body.String(), "done")
+}
+
+func TestTimeout_PassesThroughResponsesInTime(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	handler := New(time.Second).ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		deadline, ok := r.Context().Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, deadline, time.Now().Add(time.Second), 100*time.Millisecond)
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte("created"))
+	}))
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusCreated)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "text/plain")
+	assert.Equal(t, recorder.Body.String(), "created")
+}
+
+func TestTimeout_HasAConfigurableResponse(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	New(10*time.Millisecond, Status(http.StatusGatewayTimeout)).ChainLink(slow(200*time.Millisecond, nil)).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusGatewayTimeout)
+
+	recorder = httptest.NewRecorder()
+	custom := Response(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusGatewayTimeout)
+		rw.Write([]byte("try again later"))
+	}))
+	New(10*time.Millisecond, custom).ChainLink(slow(200*time.Millisecond, nil)).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusGatewayTimeout)
+	assert.Equal(t, recorder.Body.String(), "try again later")
+}
+
+func TestTimeout_AbortsResponsesAlreadyStarted(t *testing.T) {
+	handler := New(10 * time.Millisecond).ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("partial"))
+		time.Sleep(200 * time.Millisecond)
+	}))
+	recorder := httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	})
+	assert.Equal(t, recorder.Body.String(), "partial")
+}
+
+func TestTimeout_RepanicsOnTheRequestGoroutine(t *testing.T) {
+	handler := New(time.Second).ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	defer func() {
+		p, ok := recover().(*recovery.Panic)
+		assert.True(t, ok)
+		assert.Equal(t, p.Value, "boom")
+		assert.Contains(t, string(p.Stack), "TestTimeout_RepanicsOnTheRequestGoroutine.func1")
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestTimeout_PanicsAreReportedWithTheStackOfTheHandler(t *testing.T) {
+	events := make(chan *recovery.Event, 1)
+	reporting := recovery.Reporting(recovery.ReporterFunc(func(ctx context.Context, event *recovery.Event) {
+		events <- event
+	}))
+	handler := recovery.New(reporting).ChainLink(New(time.Second).ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	event := <-events
+	assert.Equal(t, event.Value, "boom")
+	assert.Contains(t, event.Frames[0].Function, "TestTimeout_PanicsAreReportedWithTheStackOfTheHandler.func2")
+	assert.False(t, event.Goroutine)
+}
+
+func TestTimeout_ReportsPanicsAfterTheDeadline(t *testing.T) {
+	events := make(chan *recovery.Event, 1)
+	reporting := recovery.Reporting(recovery.ReporterFunc(func(ctx context.Context, event *recovery.Event) {
+		events <- event
+	}))
+	handler := recovery.New(reporting).ChainLink(New(10 * time.Millisecond).ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		panic("late")
+	})))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+	event := <-events
+	assert.Equal(t, event.Value, "late")
+	assert.True(t, event.Goroutine)
+	assert.Contains(t, event.Frames[0].Function, "TestTimeout_ReportsPanicsAfterTheDeadline.func2")
+}
+
+func TestTimeout_TracesTheHandlerWithoutRacingTheTrace(t *testing.T) {
+	g := gonion.New()
+	tracer := g.EnableTracing()
+	handled := make(chan struct{})
+	g.Use().Named("timeout").ChainLink(New(10 * time.Millisecond).ChainLink)
+	g.Use().Named("slow").Func(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	})
+	g.Get("/", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		close(handled)
+	}))
+	recorder := httptest.NewRecorder()
+	g.BuildRoutes()[0].Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+	<-handled
+	report := tracer.Report()
+	assert.Equal(t, len(report), 1)
+	assert.Equal(t, report[0].Layer, "timeout")
+
+	g = gonion.New()
+	tracer = g.EnableTracing()
+	g.Use().Named("timeout").ChainLink(New(time.Second).ChainLink)
+	g.Get("/", slow(0, nil))
+	g.BuildRoutes()[0].Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, len(tracer.Report()), 2)
+}
+
+func TestTimeout_DoesNotRespondToCanceledRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	recorder := httptest.NewRecorder()
+	New(time.Second).ChainLink(slow(200*time.Millisecond, nil)).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	assert.Equal(t, recorder.Body.String(), "")
+}
+
+func TestTimeout_UsesTheRouteMetadataForLongerRoutes(t *testing.T) {
+	g := gonion.New()
+	g.Sub("/api", func(api *gonion.Composer) {
+		api.Use().RouteChainLink(New(10 * time.Millisecond).RouteChainLink)
+		api.Get("/users", slow(200*time.Millisecond, nil))
+		api.Get("/export", slow(50*time.Millisecond, nil)).Meta(Key, time.Second)
+	})
+	handlers := make(map[string]http.Handler)
+	for _, route := range g.BuildRoutes() {
+		handlers[route.Pattern] = route.Handler
+	}
+	recorder := httptest.NewRecorder()
+	handlers["/api/users"].ServeHTTP(recorder, httptest.NewRequest("GET", "/api/users", nil))
+	assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+
+	recorder = httptest.NewRecorder()
+	handlers["/api/export"].ServeHTTP(recorder, httptest.NewRequest("GET", "/api/export", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Body.String(), "done")
+}
+
+func TestTimeout_CanBeScopedWithOnly(t *testing.T) {
+	exports := func(route *gonion.RouteModel) bool {
+		return route.Pattern == "/api/export"
+	}
+	g := gonion.New()
+	g.Sub("/api", func(api *gonion.Composer) {
+		api.Only().WhenRouteMatches(func(route *gonion.RouteModel) bool {
+			return !exports(route)
+		}).Use().ChainLink(New(10 * time.Millisecond).ChainLink)
+		api.Only().WhenRouteMatches(exports).Use().ChainLink(New(time.Second).ChainLink)
+		api.Get("/export", slow(50*time.Millisecond, nil))
+	})
+	recorder := httptest.NewRecorder()
+	g.BuildRoutes()[0].Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/export", nil))
+	assert.Equal(t, recorder.Body.String(), "done")
+}
+
+func TestTimeout_StreamsWithFlush(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	New(time.Second).ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("row"))
+		assert.NoError(t, http.NewResponseController(rw).Flush())
+	})).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.True(t, recorder.Flushed)
+}
+
+func TestTimeout_TheHandlerKeepsTheHeadersSetBeforeIt(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("Vary", "Origin")
+	recorder.Header().Set("X-Removed", "true")
+	New(time.Second).ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, rw.Header().Get("Vary"), "Origin")
+		rw.Header().Add("Vary", "Accept")
+		rw.Header().Del("X-Removed")
+		rw.Write([]byte("negotiated"))
+	})).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, recorder.Header().Values("Vary"), []string{"Origin", "Accept"})
+	assert.Equal(t, recorder.Header().Get("X-Removed"), "")
+}
+
+func TestTimeout_KeepsTheInterfacesOfTheResponseWriter(t *testing.T) {
+	server := httptest.NewServer(New(time.Second).ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, ok := rw.(http.Hijacker)
+		assert.True(t, ok)
+		_, ok = rw.(io.ReaderFrom)
+		assert.True(t, ok)
+		assert.NoError(t, http.NewResponseController(rw).SetWriteDeadline(time.Now().Add(time.Minute)))
+		io.Copy(rw, strings.NewReader("exported"))
+	})))
+	defer server.Close()
+	response, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	assert.Equal(t, string(body), "exported")
+}
+
+func TestTimeout_DoesNotRespondOnHijackedConnections(t *testing.T) {
+	server := httptest.NewServer(New(10 * time.Millisecond).ChainLink(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, buffer, err := rw.(http.Hijacker).Hijack()
+		assert.NoError(t, err)
+		defer conn.Close()
+		//the context is still canceled at the deadline, the handler has to detach from it
+		detached := context.WithoutCancel(r.Context())
+		<-r.Context().Done()
+		assert.Equal(t, r.Context().Err(), context.DeadlineExceeded)
+		time.Sleep(20 * time.Millisecond)
+		assert.NoError(t, detached.Err())
+		buffer.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		buffer.Flush()
+	})))
+	defer server.Close()
+	response, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	assert.Equal(t, response.StatusCode, http.StatusOK)
+	assert.Equal(t, string(body), "hijacked")
+}
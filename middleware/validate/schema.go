@@ -0,0 +1,296 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/CoreyKaylor/gonion/openapi"
+)
+
+type schemaValidator struct {
+	doc      *openapi.Document
+	patterns map[string]*regexp.Regexp
+}
+
+//value validates a decoded JSON value and returns a message for each violation,
+//prefixed by the location of the offending value such as items[0].name.
+func (sv *schemaValidator) value(schema *openapi.Schema, value interface{}, at string) []string {
+	schema, err := sv.doc.Resolve(schema)
+	if err != nil {
+		return []string{violation(at, "%v", err)}
+	}
+	if schema == nil {
+		return nil
+	}
+	if len(schema.Type) > 0 && !matchesType(schema.Type, value) {
+		return []string{violation(at, "must be of type %s", strings.Join(schema.Type, " or "))}
+	}
+	problems := sv.composition(schema, value, at)
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		problems = append(problems, violation(at, "must be one of %v", schema.Enum))
+	}
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			problems = append(problems, violation(at, "must be at least %d characters", *schema.MinLength))
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			problems = append(problems, violation(at, "must be at most %d characters", *schema.MaxLength))
+		}
+		if schema.Pattern != "" {
+			pattern, err := sv.pattern(schema.Pattern)
+			if err != nil {
+				problems = append(problems, violation(at, "%v", err))
+			} else if !pattern.MatchString(v) {
+				problems = append(problems, violation(at, "must match %s", schema.Pattern))
+			}
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if schema.Minimum != nil && n < *schema.Minimum {
+			problems = append(problems, violation(at, "must be at least %v", *schema.Minimum))
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			problems = append(problems, violation(at, "must be at most %v", *schema.Maximum))
+		}
+	case []interface{}:
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			problems = append(problems, violation(at, "must have at least %d items", *schema.MinItems))
+		}
+		if schema.MaxItems != nil && len(v) > *schema.MaxItems {
+			problems = append(problems, violation(at, "must have at most %d items", *schema.MaxItems))
+		}
+		if schema.Items != nil {
+			for i, item := range v {
+				problems = append(problems, sv.value(schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				problems = append(problems, violation(at, "is missing required property %q", name))
+			}
+		}
+		for name, property := range v {
+			child := name
+			if at != "" {
+				child = at + "." + name
+			}
+			if propertySchema, ok := schema.Properties[name]; ok {
+				problems = append(problems, sv.value(propertySchema, property, child)...)
+			} else if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				problems = append(problems, violation(child, "is not an allowed property"))
+			}
+		}
+	}
+	return problems
+}
+
+//composition validates the value against the allOf, anyOf and oneOf subschemas.
+//The value has to be valid against all of allOf, at least one of anyOf and exactly
+//one of oneOf.
+func (sv *schemaValidator) composition(schema *openapi.Schema, value interface{}, at string) []string {
+	var problems []string
+	for _, sub := range schema.AllOf {
+		problems = append(problems, sv.value(sub, value, at)...)
+	}
+	if len(schema.AnyOf) > 0 && sv.matching(schema.AnyOf, value, at) == 0 {
+		problems = append(problems, violation(at, "must match at least one of the anyOf schemas"))
+	}
+	if len(schema.OneOf) > 0 {
+		if matched := sv.matching(schema.OneOf, value, at); matched != 1 {
+			problems = append(problems, violation(at, "must match exactly one of the oneOf schemas, but matched %d", matched))
+		}
+	}
+	return problems
+}
+
+//matching counts the schemas the value is valid against
+func (sv *schemaValidator) matching(schemas []*openapi.Schema, value interface{}, at string) int {
+	matched := 0
+	for _, sub := range schemas {
+		if len(sv.value(sub, value, at)) == 0 {
+			matched++
+		}
+	}
+	return matched
+}
+
+func violation(at string, format string, args ...interface{}) string {
+	if at == "" {
+		at = "value"
+	}
+	return at + " " + fmt.Sprintf(format, args...)
+}
+
+func (sv *schemaValidator) pattern(expr string) (*regexp.Regexp, error) {
+	if pattern, ok := sv.patterns[expr]; ok {
+		return pattern, nil
+	}
+	return regexp.Compile(expr)
+}
+
+//compilePatterns compiles every pattern in the document up front so that
+//requests don't pay for it and invalid patterns are reported when loading.
+func (sv *schemaValidator) compilePatterns() error {
+	sv.patterns = make(map[string]*regexp.Regexp)
+	var visit func(*openapi.Schema) error
+	visit = func(schema *openapi.Schema) error {
+		if schema == nil {
+			return nil
+		}
+		if schema.Pattern != "" {
+			if _, ok := sv.patterns[schema.Pattern]; !ok {
+				pattern, err := regexp.Compile(schema.Pattern)
+				if err != nil {
+					return fmt.Errorf("validate: invalid pattern %q: %v", schema.Pattern, err)
+				}
+				sv.patterns[schema.Pattern] = pattern
+			}
+		}
+		for _, property := range schema.Properties {
+			if err := visit(property); err != nil {
+				return err
+			}
+		}
+		for _, subschemas := range [][]*openapi.Schema{schema.AllOf, schema.AnyOf, schema.OneOf} {
+			for _, sub := range subschemas {
+				if err := visit(sub); err != nil {
+					return err
+				}
+			}
+		}
+		return visit(schema.Items)
+	}
+	var schemas []*openapi.Schema
+	if sv.doc.Components != nil {
+		for _, schema := range sv.doc.Components.Schemas {
+			schemas = append(schemas, schema)
+		}
+	}
+	for _, item := range sv.doc.Paths {
+		for _, method := range methods {
+			operation := item.Operation(method)
+			if operation == nil {
+				continue
+			}
+			for _, param := range operation.Parameters {
+				schemas = append(schemas, param.Schema)
+			}
+			if operation.RequestBody != nil {
+				for _, media := range operation.RequestBody.Content {
+					schemas = append(schemas, media.Schema)
+				}
+			}
+			for _, response := range operation.Responses {
+				for _, media := range response.Content {
+					schemas = append(schemas, media.Schema)
+				}
+			}
+		}
+	}
+	for _, schema := range schemas {
+		if err := visit(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var methods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH", "TRACE"}
+
+//parameter converts the raw string values of a parameter into the JSON value
+//described by the schema before validating it.
+func (sv *schemaValidator) parameter(schema *openapi.Schema, values []string, at string) []string {
+	resolved, err := sv.doc.Resolve(schema)
+	if err != nil {
+		return []string{violation(at, "%v", err)}
+	}
+	if resolved == nil {
+		return nil
+	}
+	if resolved.Type.Has("array") {
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		items := make([]interface{}, len(values))
+		for i, value := range values {
+			items[i] = coerce(resolved.Items, sv.doc, value)
+		}
+		return sv.value(resolved, items, at)
+	}
+	return sv.value(resolved, coerce(resolved, sv.doc, values[0]), at)
+}
+
+func coerce(schema *openapi.Schema, doc *openapi.Document, value string) interface{} {
+	schema, _ = doc.Resolve(schema)
+	if schema == nil {
+		return value
+	}
+	switch {
+	case schema.Type.Has("integer") || schema.Type.Has("number"):
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case schema.Type.Has("boolean"):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+func matchesType(types openapi.Types, value interface{}) bool {
+	for _, typ := range types {
+		switch v := value.(type) {
+		case nil:
+			if typ == "null" {
+				return true
+			}
+		case bool:
+			if typ == "boolean" {
+				return true
+			}
+		case string:
+			if typ == "string" {
+				return true
+			}
+		case json.Number:
+			if typ == "number" {
+				return true
+			}
+			if typ == "integer" {
+				if _, err := v.Int64(); err == nil {
+					return true
+				}
+				if f, err := v.Float64(); err == nil && f == float64(int64(f)) {
+					return true
+				}
+			}
+		case []interface{}:
+			if typ == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if typ == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if reflect.DeepEqual(allowed, value) || fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
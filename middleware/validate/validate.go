@@ -0,0 +1,344 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/CoreyKaylor/gonion/openapi"
+	"github.com/CoreyKaylor/gonion/problem"
+	"github.com/CoreyKaylor/gonion/writer"
+)
+
+//Error is a single violation of the API contract
+type Error struct {
+	In      string `json:"in"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.In + ": " + e.Message
+}
+
+//Validator validates requests, and optionally responses, against the
+//operations of an OpenAPI document.
+type Validator struct {
+	schemas     *schemaValidator
+	basePath    string
+	responses   bool
+	onDrift     func(*http.Request, *gonion.RouteModel, []error)
+	maxBodySize int64
+}
+
+//DefaultMaxBodySize is the largest request body read to validate it unless configured otherwise
+const DefaultMaxBodySize = 1 << 20
+
+//Option configures a Validator
+type Option func(*Validator)
+
+//BasePath is the prefix of the route patterns that isn't part of the paths in
+//the document, typically the path of the document's server URL.
+func BasePath(prefix string) Option {
+	return func(v *Validator) {
+		v.basePath = prefix
+	}
+}
+
+//ValidateResponses enables validating responses, which is intended for development
+//since it buffers every response. Responses that drift from the contract are still
+//sent, but reported to onDrift, or logged to slog.Default() when onDrift is nil.
+func ValidateResponses(onDrift func(r *http.Request, route *gonion.RouteModel, errs []error)) Option {
+	return func(v *Validator) {
+		v.responses = true
+		if onDrift != nil {
+			v.onDrift = onDrift
+		}
+	}
+}
+
+//MaxBodySize is the largest request body that's read to validate it. Larger bodies
+//are answered with a 413 problem. Zero or less reads bodies of any size.
+func MaxBodySize(size int64) Option {
+	return func(v *Validator) {
+		v.maxBodySize = size
+	}
+}
+
+//New is a factory method for Validator
+func New(doc *openapi.Document, options ...Option) (*Validator, error) {
+	v := &Validator{
+		schemas:     &schemaValidator{doc: doc},
+		onDrift:     logDrift,
+		maxBodySize: DefaultMaxBodySize,
+	}
+	for _, option := range options {
+		option(v)
+	}
+	if err := v.schemas.compilePatterns(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+//Load is a convenience method for New that reads the document from a local file
+func Load(filename string, options ...Option) (*Validator, error) {
+	doc, err := openapi.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	return New(doc, options...)
+}
+
+//logDrift logs each violation of the response as a warning to slog.Default()
+func logDrift(r *http.Request, route *gonion.RouteModel, errs []error) {
+	for _, err := range errs {
+		slog.Default().LogAttrs(r.Context(), slog.LevelWarn, "response drifted from contract",
+			slog.String("method", route.Method),
+			slog.String("route", route.Pattern),
+			slog.String("error", err.Error()))
+	}
+}
+
+//ChainLink validates the requests of a route against the document's operation for the
+//route's method and pattern, and is intended to be registered with RouteChainLink.
+//Invalid requests are answered with a 400 problem listing every violation, and
+//bodies over the MaxBodySize with a 413 problem. Routes without an operation in
+//the document are left alone.
+func (v *Validator) ChainLink(route *gonion.RouteModel) gonion.ChainLink {
+	operation := v.operationFor(route)
+	return func(inner http.Handler) http.Handler {
+		if operation == nil {
+			return inner
+		}
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			body, ok := v.readBody(rw, r, operation)
+			if !ok {
+				return
+			}
+			errs := v.validateRequest(route, operation, r, body)
+			if len(errs) > 0 {
+				writeErrors(rw, r, errs)
+				return
+			}
+			if !v.responses {
+				inner.ServeHTTP(rw, r)
+				return
+			}
+			recorder := &responseRecorder{ResponseWriter: rw, status: http.StatusOK}
+			w := writer.Through(rw, recorder)
+			inner.ServeHTTP(w.ResponseWriter(), r)
+			hijacked := w.Hijacked()
+			w.Release()
+			if hijacked {
+				return
+			}
+			if drift := v.validateResponse(operation, recorder); len(drift) > 0 {
+				v.onDrift(r, route, drift)
+			}
+			recorder.flush()
+		})
+	}
+}
+
+func (v *Validator) operationFor(route *gonion.RouteModel) *openapi.Operation {
+	if !strings.HasPrefix(route.Pattern, v.basePath) {
+		return nil
+	}
+	path, _ := openapi.Path(strings.TrimPrefix(route.Pattern, v.basePath))
+	item, ok := v.schemas.doc.Paths[path]
+	if !ok {
+		return nil
+	}
+	return item.Operation(route.Method)
+}
+
+//readBody buffers the body of requests for operations with one, so it can be
+//validated and still be read by the handler. It answers the request itself when
+//the body can't be read.
+func (v *Validator) readBody(rw http.ResponseWriter, r *http.Request, operation *openapi.Operation) ([]byte, bool) {
+	if operation.RequestBody == nil || r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	body := r.Body
+	if v.maxBodySize > 0 {
+		body = http.MaxBytesReader(rw, body, v.maxBodySize)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			problem.New(http.StatusRequestEntityTooLarge).
+				WithDetail("The request body is larger than "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes").
+				Write(rw, r)
+		} else {
+			writeErrors(rw, r, []*Error{{In: "body", Message: "body could not be read"}})
+		}
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	return data, true
+}
+
+func (v *Validator) validateRequest(route *gonion.RouteModel, operation *openapi.Operation, r *http.Request, body []byte) []*Error {
+	var errs []*Error
+	add := func(in string, name string, messages []string) {
+		for _, message := range messages {
+			errs = append(errs, &Error{In: in, Name: name, Message: message})
+		}
+	}
+	params, _ := gonion.MatchPattern(route.Pattern, r.URL.Path)
+	query := r.URL.Query()
+	for _, param := range operation.Parameters {
+		var values []string
+		switch param.In {
+		case "path":
+			if value, ok := params[param.Name]; ok {
+				values = []string{value}
+			}
+		case "query":
+			values = query[param.Name]
+		case "header":
+			values = r.Header.Values(param.Name)
+		case "cookie":
+			if cookie, err := r.Cookie(param.Name); err == nil {
+				values = []string{cookie.Value}
+			}
+		}
+		if len(values) == 0 {
+			if param.Required {
+				add(param.In, param.Name, []string{param.Name + " is required"})
+			}
+			continue
+		}
+		add(param.In, param.Name, v.schemas.parameter(param.Schema, values, param.Name))
+	}
+	if operation.RequestBody != nil {
+		add("body", "", v.validateBody(operation.RequestBody, r, body))
+	}
+	return errs
+}
+
+func (v *Validator) validateBody(body *openapi.RequestBody, r *http.Request, data []byte) []string {
+	if len(data) == 0 {
+		if body.Required {
+			return []string{"body is required"}
+		}
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	media, ok := body.Content[mediaType]
+	if !ok {
+		return []string{"content type " + strconv.Quote(mediaType) + " is not supported"}
+	}
+	if media.Schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+	value, err := decode(data)
+	if err != nil {
+		return []string{"body is not valid JSON"}
+	}
+	return v.schemas.value(media.Schema, value, "")
+}
+
+func (v *Validator) validateResponse(operation *openapi.Operation, recorder *responseRecorder) []error {
+	var errs []error
+	response := responseFor(operation, recorder.status)
+	if response == nil {
+		return []error{&Error{In: "response", Message: "status " + strconv.Itoa(recorder.status) + " is not documented"}}
+	}
+	if recorder.body.Len() == 0 || len(response.Content) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(recorder.Header().Get("Content-Type"))
+	media, ok := response.Content[mediaType]
+	if !ok {
+		return []error{&Error{In: "response", Message: "content type " + strconv.Quote(mediaType) + " is not documented"}}
+	}
+	if media.Schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+	value, err := decode(recorder.body.Bytes())
+	if err != nil {
+		return []error{&Error{In: "response", Message: "body is not valid JSON"}}
+	}
+	for _, message := range v.schemas.value(media.Schema, value, "") {
+		errs = append(errs, &Error{In: "response", Message: message})
+	}
+	return errs
+}
+
+func responseFor(operation *openapi.Operation, status int) *openapi.Response {
+	code := strconv.Itoa(status)
+	if response, ok := operation.Responses[code]; ok {
+		return response
+	}
+	if response, ok := operation.Responses[code[:1]+"XX"]; ok {
+		return response
+	}
+	return operation.Responses["default"]
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+var errTrailingData = errors.New("validate: data after the JSON value")
+
+//decode reads a single JSON value, anything but whitespace after it is an error
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errTrailingData
+	}
+	return value, nil
+}
+
+func writeErrors(rw http.ResponseWriter, r *http.Request, errs []*Error) {
+	details := make([]interface{}, len(errs))
+	for i, err := range errs {
+		detail := map[string]interface{}{"in": err.In, "message": err.Message}
+		if err.Name != "" {
+			detail["name"] = err.Name
+		}
+		details[i] = detail
+	}
+	problem.New(http.StatusBadRequest).
+		WithDetail("The request does not match the API contract").
+		With("errors", details).
+		Write(rw, r)
+}
+
+//responseRecorder buffers the response until it's validated, so flushing it
+//does nothing
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	return rr.body.Write(b)
+}
+
+func (rr *responseRecorder) flush() {
+	rr.ResponseWriter.WriteHeader(rr.status)
+	rr.ResponseWriter.Write(rr.body.Bytes())
+}
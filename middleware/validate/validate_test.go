@@ -0,0 +1,251 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/CoreyKaylor/gonion/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const contract = `
+openapi: 3.1.0
+info:
+  title: Users
+  version: "1.0"
+paths:
+  /users/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+        - name: fields
+          in: query
+          schema:
+            type: array
+            items:
+              type: string
+              enum: [name, email]
+      responses:
+        "200":
+          description: The user
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/User"
+  /users:
+    post:
+      parameters:
+        - name: X-Tenant
+          in: header
+          required: true
+          schema:
+            type: string
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/User"
+      responses:
+        "201":
+          description: Created
+components:
+  schemas:
+    User:
+      type: object
+      required: [name]
+      additionalProperties: false
+      properties:
+        name:
+          type: string
+          minLength: 1
+        age:
+          type: integer
+          minimum: 0
+`
+
+func composeWith(t *testing.T, options []Option, userJSON string) gonion.Routes {
+	doc, err := openapi.Parse([]byte(contract))
+	assert.Nil(t, err)
+	validator, err := New(doc, options...)
+	assert.Nil(t, err)
+	g := gonion.New()
+	g.Sub("/v1", func(v1 *gonion.Composer) {
+		v1.Use().RouteChainLink(validator.ChainLink)
+		v1.Get("/users/:id", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write([]byte(userJSON))
+		}))
+		v1.Post("/users", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusCreated)
+		}))
+		v1.Get("/health", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	})
+	return g.BuildRoutes()
+}
+
+func serve(routes gonion.Routes, method string, pattern string, request *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	for _, route := range routes {
+		if route.Method == method && route.Pattern == pattern {
+			route.Handler.ServeHTTP(recorder, request)
+		}
+	}
+	return recorder
+}
+
+func TestValidRequestsReachTheHandler(t *testing.T) {
+	routes := composeWith(t, []Option{BasePath("/v1")}, `{"name":"bob"}`)
+	request := httptest.NewRequest("GET", "/v1/users/5?fields=name,email", nil)
+	recorder := serve(routes, "GET", "/v1/users/:id", request)
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Body.String(), `{"name":"bob"}`)
+}
+
+func TestInvalidParametersAreRejected(t *testing.T) {
+	routes := composeWith(t, []Option{BasePath("/v1")}, `{}`)
+	request := httptest.NewRequest("GET", "/v1/users/bob?fields=name,phone", nil)
+	recorder := serve(routes, "GET", "/v1/users/:id", request)
+	body := recorder.Body.String()
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/problem+json")
+	assert.True(t, strings.Contains(body, `{"in":"path","message":"id must be of type integer","name":"id"}`))
+	assert.True(t, strings.Contains(body, `"message":"fields[1] must be one of [name email]"`))
+}
+
+func TestInvalidBodiesAreRejected(t *testing.T) {
+	routes := composeWith(t, []Option{BasePath("/v1")}, `{}`)
+	request := httptest.NewRequest("POST", "/v1/users", strings.NewReader(`{"age":-1,"admin":true}`))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := serve(routes, "POST", "/v1/users", request)
+	body := recorder.Body.String()
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+	assert.True(t, strings.Contains(body, `X-Tenant is required`))
+	assert.True(t, strings.Contains(body, `value is missing required property \"name\"`))
+	assert.True(t, strings.Contains(body, `age must be at least 0`))
+	assert.True(t, strings.Contains(body, `admin is not an allowed property`))
+}
+
+func TestBodiesWithDataAfterTheJSONValueAreRejected(t *testing.T) {
+	routes := composeWith(t, []Option{BasePath("/v1")}, `{}`)
+	for _, body := range []string{`{"name":"bob"} {"name":"eve"}`, `{"name":"bob"}}`, `{"name":"bob"}x`} {
+		request := httptest.NewRequest("POST", "/v1/users", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Tenant", "acme")
+		recorder := serve(routes, "POST", "/v1/users", request)
+		assert.Equal(t, recorder.Code, http.StatusBadRequest, body)
+		assert.Contains(t, recorder.Body.String(), "body is not valid JSON", body)
+	}
+	request := httptest.NewRequest("POST", "/v1/users", strings.NewReader("{\"name\":\"bob\"}\n"))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Tenant", "acme")
+	assert.Equal(t, serve(routes, "POST", "/v1/users", request).Code, http.StatusCreated)
+}
+
+func TestSchemasAreComposedWithAllOfAnyOfAndOneOf(t *testing.T) {
+	doc, err := openapi.Parse([]byte(`{
+		"openapi": "3.1.0",
+		"components": {"schemas": {
+			"Named": {"type": "object", "required": ["name"]},
+			"Card": {"type": "object", "required": ["card"]},
+			"Bank": {"type": "object", "required": ["iban"]},
+			"Payment": {
+				"allOf": [{"$ref": "#/components/schemas/Named"}],
+				"oneOf": [{"$ref": "#/components/schemas/Card"}, {"$ref": "#/components/schemas/Bank"}]
+			},
+			"Id": {"anyOf": [{"type": "integer"}, {"type": "string", "pattern": "^[a-z]+$"}]}
+		}}
+	}`))
+	assert.Nil(t, err)
+	sv := &schemaValidator{doc: doc}
+	assert.Nil(t, sv.compilePatterns())
+	payment, id := openapi.Ref("Payment"), openapi.Ref("Id")
+	assert.Equal(t, len(sv.value(payment, map[string]interface{}{"name": "a", "card": "4242"}, "")), 0)
+	assert.Equal(t, sv.value(payment, map[string]interface{}{"card": "4242"}, ""), []string{`value is missing required property "name"`})
+	assert.Equal(t, sv.value(payment, map[string]interface{}{"name": "a", "card": "4242", "iban": "DE00"}, ""),
+		[]string{"value must match exactly one of the oneOf schemas, but matched 2"})
+	assert.Equal(t, sv.value(payment, map[string]interface{}{"name": "a"}, ""),
+		[]string{"value must match exactly one of the oneOf schemas, but matched 0"})
+	assert.Equal(t, len(sv.value(id, json.Number("5"), "id")), 0)
+	assert.Equal(t, len(sv.value(id, "abc", "id")), 0)
+	assert.Equal(t, sv.value(id, "ABC", "id"), []string{"id must match at least one of the anyOf schemas"})
+}
+
+func TestBodiesOverTheMaxBodySizeAreRejected(t *testing.T) {
+	routes := composeWith(t, []Option{BasePath("/v1"), MaxBodySize(16)}, `{}`)
+	request := httptest.NewRequest("POST", "/v1/users", strings.NewReader(`{"name":"`+strings.Repeat("a", 32)+`"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Tenant", "acme")
+	recorder := serve(routes, "POST", "/v1/users", request)
+	assert.Equal(t, recorder.Code, http.StatusRequestEntityTooLarge)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/problem+json")
+
+	request = httptest.NewRequest("POST", "/v1/users", strings.NewReader(`{"name":"bob"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Tenant", "acme")
+	recorder = serve(routes, "POST", "/v1/users", request)
+	assert.Equal(t, recorder.Code, http.StatusCreated)
+}
+
+func TestRoutesMissingFromTheContractAreIgnored(t *testing.T) {
+	routes := composeWith(t, []Option{BasePath("/v1")}, `{}`)
+	recorder := serve(routes, "GET", "/v1/health", httptest.NewRequest("GET", "/v1/health", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+}
+
+func TestResponseDriftIsReported(t *testing.T) {
+	var drift []error
+	onDrift := func(r *http.Request, route *gonion.RouteModel, errs []error) {
+		drift = errs
+	}
+	routes := composeWith(t, []Option{BasePath("/v1"), ValidateResponses(onDrift)}, `{"name":"bob","age":"old"}`)
+	recorder := serve(routes, "GET", "/v1/users/:id", httptest.NewRequest("GET", "/v1/users/5", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, recorder.Body.String(), `{"name":"bob","age":"old"}`)
+	assert.Equal(t, len(drift), 1)
+	assert.Equal(t, drift[0].Error(), "response: age must be of type integer")
+}
+
+func TestResponseDriftIsLoggedToSlogByDefault(t *testing.T) {
+	var buffer bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buffer, nil)))
+	routes := composeWith(t, []Option{BasePath("/v1"), ValidateResponses(nil)}, `{"name":"bob","age":"old"}`)
+	serve(routes, "GET", "/v1/users/:id", httptest.NewRequest("GET", "/v1/users/5", nil))
+	var logged map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &logged))
+	assert.Equal(t, logged["level"], "WARN")
+	assert.Equal(t, logged["msg"], "response drifted from contract")
+	assert.Equal(t, logged["method"], "GET")
+	assert.Equal(t, logged["route"], "/v1/users/:id")
+	assert.Equal(t, logged["error"], "response: age must be of type integer")
+}
+
+func TestValidatedResponsesKeepTheInterfacesOfTheWrappedWriter(t *testing.T) {
+	doc, err := openapi.Parse([]byte(contract))
+	assert.Nil(t, err)
+	validator, err := New(doc, BasePath("/v1"), ValidateResponses(nil))
+	assert.Nil(t, err)
+	g := gonion.New()
+	g.Use().RouteChainLink(validator.ChainLink)
+	g.Get("/v1/users/:id", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"name":`))
+		rw.(http.Flusher).Flush()
+		rw.Write([]byte(`"bob"}`))
+	}))
+	recorder := httptest.NewRecorder()
+	g.BuildRoutes()[0].Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/v1/users/5", nil))
+	assert.False(t, recorder.Flushed)
+	assert.Equal(t, recorder.Body.String(), `{"name":"bob"}`)
+}
@@ -0,0 +1,72 @@
+package gonion
+
+import (
+	"net/http"
+
+	"github.com/CoreyKaylor/gonion/negotiation"
+	"github.com/CoreyKaylor/gonion/problem"
+)
+
+//Produces declares the media types the route's handler produces. Routes with the
+//same method and pattern that produce different media types are combined into one
+//route that dispatches on the request's Accept header, answering 406 when none of
+//them are acceptable.
+func (ro *RouteOptions) Produces(mediaTypes ...string) *RouteOptions {
+	ro.route.Produces = append(ro.route.Produces, mediaTypes...)
+	return ro
+}
+
+type negotiatedHandler struct {
+	offers   []string
+	handlers map[string]http.Handler
+}
+
+func newNegotiatedHandler() *negotiatedHandler {
+	return &negotiatedHandler{
+		handlers: make(map[string]http.Handler),
+	}
+}
+
+//combine makes one route of the routes sharing a method and pattern that produce
+//different media types. Its handler negotiates which of theirs to dispatch to, so
+//the route's middleware runs once before negotiating, including for requests that
+//are answered with 406. The earliest registration is the preferred offer and its
+//metadata takes precedence.
+func combine(variants []*RouteModel) *RouteModel {
+	negotiated := newNegotiatedHandler()
+	combined := &RouteModel{
+		Method:   variants[0].Method,
+		Pattern:  variants[0].Pattern,
+		Handler:  negotiated,
+		Metadata: Metadata{},
+	}
+	for _, variant := range variants {
+		for _, mediaType := range variant.Produces {
+			if _, ok := negotiated.handlers[mediaType]; !ok {
+				negotiated.handlers[mediaType] = variant.Handler
+				negotiated.offers = append(negotiated.offers, mediaType)
+			}
+		}
+		for key, value := range variant.Metadata {
+			if _, ok := combined.Metadata[key]; !ok {
+				combined.Metadata[key] = value
+			}
+		}
+		combined.requires = append(combined.requires, variant.requires...)
+	}
+	combined.Produces = negotiated.offers
+	return combined
+}
+
+func (n *negotiatedHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Add("Vary", "Accept")
+	mediaType := negotiation.Negotiate(r, n.offers...)
+	if mediaType == "" {
+		problem.New(http.StatusNotAcceptable).With("acceptable", n.offers).Write(rw, r)
+		return
+	}
+	if rw.Header().Get("Content-Type") == "" {
+		rw.Header().Set("Content-Type", mediaType)
+	}
+	n.handlers[mediaType].ServeHTTP(rw, r)
+}
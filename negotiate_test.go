@@ -0,0 +1,60 @@
+package gonion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func composeNegotiatedRoutes() Routes {
+	g := New()
+	g.Use().ChainLink(func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Add("X-Middleware", "true")
+			inner.ServeHTTP(rw, r)
+		})
+	})
+	g.Get("/users", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("json"))
+	})).Produces("application/json")
+	g.Get("/users", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("html"))
+	})).Produces("text/html")
+	return g.BuildRoutes()
+}
+
+func serveAccepting(route *Route, accept string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/users", nil)
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	route.Handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestProduces_CombinesRoutesByMediaType(t *testing.T) {
+	routes := composeNegotiatedRoutes()
+	assert.Equal(t, len(routes), 1)
+	route := routes.routeFor("GET", "/users")
+	assert.Equal(t, route.Produces, []string{"application/json", "text/html"})
+
+	recorder := serveAccepting(route, "text/html,application/xhtml+xml,*/*;q=0.8")
+	assert.Equal(t, recorder.Body.String(), "html")
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "text/html")
+	assert.Equal(t, recorder.Header().Get("Vary"), "Accept")
+	assert.Equal(t, recorder.Header().Values("X-Middleware"), []string{"true"})
+
+	recorder = serveAccepting(route, "")
+	assert.Equal(t, recorder.Body.String(), "json")
+}
+
+func TestProduces_NotAcceptable(t *testing.T) {
+	route := composeNegotiatedRoutes().routeFor("GET", "/users")
+	recorder := serveAccepting(route, "image/png")
+	assert.Equal(t, recorder.Code, http.StatusNotAcceptable)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/problem+json")
+	assert.Equal(t, recorder.Header().Get("X-Middleware"), "true")
+}
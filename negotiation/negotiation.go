@@ -0,0 +1,223 @@
+package negotiation
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Spec is a single entry of an Accept style header such as text/html;q=0.8
+type Spec struct {
+	Value  string
+	Q      float64
+	Params map[string]string
+}
+
+//Parse parses the value of an Accept, Accept-Language, Accept-Encoding or
+//Accept-Charset header. Entries are returned in order of preference, invalid
+//entries are skipped.
+func Parse(header string) []Spec {
+	specs := make([]Spec, 0, 4)
+	for _, entry := range strings.Split(header, ",") {
+		parts := strings.Split(entry, ";")
+		value := strings.ToLower(strings.TrimSpace(parts[0]))
+		if value == "" {
+			continue
+		}
+		spec := Spec{Value: value, Q: 1}
+		valid := true
+		for _, param := range parts[1:] {
+			name, paramValue, _ := strings.Cut(param, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			paramValue = strings.Trim(strings.TrimSpace(paramValue), `"`)
+			if name == "q" {
+				q, ok := parseQ(paramValue)
+				if !ok {
+					valid = false
+					break
+				}
+				spec.Q = q
+				continue
+			}
+			if spec.Params == nil {
+				spec.Params = make(map[string]string)
+			}
+			spec.Params[name] = paramValue
+		}
+		if valid {
+			specs = append(specs, spec)
+		}
+	}
+	sort.SliceStable(specs, func(i, j int) bool {
+		return specs[i].Q > specs[j].Q
+	})
+	return specs
+}
+
+//parseQ parses a qvalue as defined by RFC 9110, a number between 0 and 1
+//with at most three decimals.
+func parseQ(value string) (float64, bool) {
+	whole, fraction, _ := strings.Cut(value, ".")
+	if (whole != "0" && whole != "1") || len(fraction) > 3 {
+		return 0, false
+	}
+	q, err := strconv.ParseFloat(value, 64)
+	if err != nil || q > 1 {
+		return 0, false
+	}
+	return q, true
+}
+
+//matcher reports how specifically a range matches an offer, where a
+//higher specificity wins and a negative one means no match.
+type matcher func(spec Spec, offer string) int
+
+//negotiate returns the offer with the highest q value, using the q value of the
+//most specific range matching each offer. Ties are broken by the order of the offers.
+func negotiate(specs []Spec, offers []string, match matcher) string {
+	best := ""
+	bestQ := 0.0
+	for _, offer := range offers {
+		q := 0.0
+		specificity := -1
+		for _, spec := range specs {
+			if s := match(spec, offer); s > specificity {
+				specificity = s
+				q = spec.Q
+			}
+		}
+		if q > bestQ {
+			best = offer
+			bestQ = q
+		}
+	}
+	return best
+}
+
+//Negotiate returns the media type offer that best fits the request's Accept
+//header, or an empty string when none of them are acceptable. The first offer
+//is returned when the request doesn't have an Accept header.
+func Negotiate(r *http.Request, offers ...string) string {
+	header, ok := headerValue(r, "Accept")
+	if !ok {
+		return first(offers)
+	}
+	return negotiate(Parse(header), offers, matchMediaType)
+}
+
+func matchMediaType(spec Spec, offer string) int {
+	offerType, offerParams := splitMediaType(offer)
+	mediaType, subtype, _ := strings.Cut(spec.Value, "/")
+	offerMain, offerSub, _ := strings.Cut(offerType, "/")
+	specificity := 0
+	switch {
+	case mediaType == "*" && subtype == "*":
+	case mediaType == offerMain && subtype == "*":
+		specificity = 1
+	case mediaType == offerMain && subtype == offerSub:
+		specificity = 2
+	default:
+		return -1
+	}
+	for name, value := range spec.Params {
+		if !strings.EqualFold(offerParams[name], value) {
+			return -1
+		}
+		specificity++
+	}
+	return specificity
+}
+
+func splitMediaType(mediaType string) (string, map[string]string) {
+	parts := strings.Split(mediaType, ";")
+	params := make(map[string]string)
+	for _, param := range parts[1:] {
+		name, value, _ := strings.Cut(param, "=")
+		params[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return strings.ToLower(strings.TrimSpace(parts[0])), params
+}
+
+//NegotiateLanguage returns the language tag offer that best fits the request's
+//Accept-Language header using the basic filtering of RFC 4647, where en matches
+//en-US. The first offer is returned when the request doesn't have the header.
+func NegotiateLanguage(r *http.Request, offers ...string) string {
+	header, ok := headerValue(r, "Accept-Language")
+	if !ok {
+		return first(offers)
+	}
+	return negotiate(Parse(header), offers, func(spec Spec, offer string) int {
+		offer = strings.ToLower(offer)
+		switch {
+		case spec.Value == "*":
+			return 0
+		case spec.Value == offer, strings.HasPrefix(offer, spec.Value+"-"):
+			return len(spec.Value)
+		}
+		return -1
+	})
+}
+
+//NegotiateEncoding returns the content coding offer that best fits the request's
+//Accept-Encoding header. As defined by RFC 9110, identity is acceptable unless
+//it's excluded explicitly or by *;q=0.
+func NegotiateEncoding(r *http.Request, offers ...string) string {
+	header, ok := headerValue(r, "Accept-Encoding")
+	if !ok {
+		return first(offers)
+	}
+	specs := Parse(header)
+	if !mentionsIdentity(specs) {
+		specs = append(specs, Spec{Value: "identity", Q: 0.001})
+	}
+	return negotiate(specs, offers, matchToken)
+}
+
+func mentionsIdentity(specs []Spec) bool {
+	for _, spec := range specs {
+		if spec.Value == "identity" || spec.Value == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+//NegotiateCharset returns the charset offer that best fits the request's
+//Accept-Charset header. The first offer is returned when the request doesn't
+//have the header.
+func NegotiateCharset(r *http.Request, offers ...string) string {
+	header, ok := headerValue(r, "Accept-Charset")
+	if !ok {
+		return first(offers)
+	}
+	return negotiate(Parse(header), offers, matchToken)
+}
+
+func matchToken(spec Spec, offer string) int {
+	switch {
+	case spec.Value == "*":
+		return 0
+	case spec.Value == strings.ToLower(offer):
+		return 1
+	}
+	return -1
+}
+
+func headerValue(r *http.Request, name string) (string, bool) {
+	if r == nil || r.Header == nil {
+		return "", false
+	}
+	values, ok := r.Header[name]
+	if !ok {
+		return "", false
+	}
+	return strings.Join(values, ","), true
+}
+
+func first(offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	return offers[0]
+}
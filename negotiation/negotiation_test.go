@@ -0,0 +1,61 @@
+package negotiation
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestWith(name string, value string) *http.Request {
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set(name, value)
+	return r
+}
+
+func TestParse_SkipsInvalidQValues(t *testing.T) {
+	specs := Parse("text/html;q=0.5, application/json, text/plain;q=2, */*;q=0.1234")
+	assert.Equal(t, specs, []Spec{
+		{Value: "application/json", Q: 1},
+		{Value: "text/html", Q: 0.5},
+	})
+}
+
+func TestNegotiate_UsesMostSpecificRange(t *testing.T) {
+	r := requestWith("Accept", "text/*;q=0.3, text/html;q=0.7, text/html;level=1, */*;q=0.5")
+	assert.Equal(t, Negotiate(r, "text/plain", "application/json"), "application/json")
+	assert.Equal(t, Negotiate(r, "text/plain", "text/html"), "text/html")
+	assert.Equal(t, Negotiate(r, "text/html", "text/html;level=1"), "text/html;level=1")
+}
+
+func TestNegotiate_ExcludesZeroQuality(t *testing.T) {
+	r := requestWith("Accept", "application/json, text/html;q=0")
+	assert.Equal(t, Negotiate(r, "text/html"), "")
+	assert.Equal(t, Negotiate(r, "text/html", "application/json"), "application/json")
+}
+
+func TestNegotiate_PrefersOfferOrderOnTies(t *testing.T) {
+	r := requestWith("Accept", "*/*")
+	assert.Equal(t, Negotiate(r, "application/xml", "application/json"), "application/xml")
+	assert.Equal(t, Negotiate(new(http.Request), "application/json", "application/xml"), "application/json")
+}
+
+func TestNegotiateLanguage_MatchesPrefixes(t *testing.T) {
+	r := requestWith("Accept-Language", "fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5")
+	assert.Equal(t, NegotiateLanguage(r, "en-US", "fr-FR"), "fr-FR")
+	assert.Equal(t, NegotiateLanguage(r, "de", "en-GB"), "en-GB")
+	assert.Equal(t, NegotiateLanguage(r, "de"), "de")
+}
+
+func TestNegotiateEncoding_IdentityIsImplicit(t *testing.T) {
+	assert.Equal(t, NegotiateEncoding(requestWith("Accept-Encoding", "br"), "gzip", "identity"), "identity")
+	assert.Equal(t, NegotiateEncoding(requestWith("Accept-Encoding", "gzip;q=0.5, deflate"), "gzip", "deflate"), "deflate")
+	assert.Equal(t, NegotiateEncoding(requestWith("Accept-Encoding", "*;q=0"), "identity"), "")
+	assert.Equal(t, NegotiateEncoding(requestWith("Accept-Encoding", ""), "gzip", "identity"), "identity")
+}
+
+func TestNegotiateCharset(t *testing.T) {
+	r := requestWith("Accept-Charset", "iso-8859-5, utf-8;q=0.8")
+	assert.Equal(t, NegotiateCharset(r, "UTF-8", "ISO-8859-5"), "ISO-8859-5")
+	assert.Equal(t, NegotiateCharset(r, "us-ascii"), "")
+}
@@ -0,0 +1,65 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//Load reads an OpenAPI document from a local JSON or YAML file
+func Load(filename string) (*Document, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %s: %v", filename, err)
+	}
+	return doc, nil
+}
+
+//Parse reads an OpenAPI document from JSON or YAML
+func Parse(data []byte) (*Document, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		converted, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	}
+	doc := &Document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+//Resolve follows a local reference such as #/components/schemas/User,
+//returning the schema itself when it isn't a reference.
+func (doc *Document) Resolve(schema *Schema) (*Schema, error) {
+	const prefix = "#/components/schemas/"
+	for depth := 0; schema != nil && schema.Ref != ""; depth++ {
+		if depth > 32 || !strings.HasPrefix(schema.Ref, prefix) {
+			return nil, fmt.Errorf("openapi: unsupported reference %q", schema.Ref)
+		}
+		var resolved *Schema
+		if doc.Components != nil {
+			resolved = doc.Components.Schemas[strings.TrimPrefix(schema.Ref, prefix)]
+		}
+		if resolved == nil {
+			return nil, fmt.Errorf("openapi: unresolved reference %q", schema.Ref)
+		}
+		schema = resolved
+	}
+	return schema, nil
+}
@@ -0,0 +1,171 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/CoreyKaylor/gonion/problem"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	//OperationKey is the route metadata key for an *Operation describing the route
+	OperationKey = "openapi.operation"
+	//SecurityKey is the route or middleware metadata key for the SecurityRequirement,
+	//or []SecurityRequirement, of the routes it applies to
+	SecurityKey = "openapi.security"
+	//HiddenKey is the route metadata key that excludes a route from the document when true
+	HiddenKey = "openapi.hidden"
+)
+
+//Config is the document level information that can't be derived from the routes
+type Config struct {
+	Info            Info
+	Servers         []Server
+	Schemas         map[string]*Schema
+	SecuritySchemes map[string]*SecurityScheme
+}
+
+//Generate creates an OpenAPI document describing the routes. Path parameters are
+//derived from the route patterns and everything else from route metadata.
+func Generate(routes gonion.Routes, config Config) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    config.Info,
+		Servers: config.Servers,
+		Paths:   make(map[string]*PathItem),
+	}
+	if len(config.Schemas) > 0 || len(config.SecuritySchemes) > 0 {
+		doc.Components = &Components{
+			Schemas:         config.Schemas,
+			SecuritySchemes: config.SecuritySchemes,
+		}
+	}
+	for _, route := range routes {
+		if hidden, _ := route.Metadata[HiddenKey].(bool); hidden {
+			continue
+		}
+		path, params := Path(route.Pattern)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		item.SetOperation(route.Method, operationFor(route, params))
+	}
+	return doc
+}
+
+func operationFor(route *gonion.Route, params []string) *Operation {
+	operation := &Operation{}
+	if described, ok := route.Metadata[OperationKey].(*Operation); ok {
+		copied := *described
+		operation = &copied
+	}
+	if name, ok := route.Metadata[gonion.NameKey].(string); ok && operation.OperationID == "" {
+		operation.OperationID = name
+	}
+	operation.Parameters = append([]*Parameter(nil), operation.Parameters...)
+	for _, param := range params {
+		if !hasPathParameter(operation, param) {
+			operation.Parameters = append(operation.Parameters, &Parameter{
+				Name:     param,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: Types{"string"}},
+			})
+		}
+	}
+	if operation.Security == nil {
+		switch security := route.Metadata[SecurityKey].(type) {
+		case SecurityRequirement:
+			operation.Security = []SecurityRequirement{security}
+		case []SecurityRequirement:
+			operation.Security = security
+		}
+	}
+	if len(operation.Responses) == 0 {
+		operation.Responses = map[string]*Response{
+			"default": {Description: "Default response"},
+		}
+	}
+	return operation
+}
+
+func hasPathParameter(operation *Operation, name string) bool {
+	for _, param := range operation.Parameters {
+		if param.In == "path" && param.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+//Path converts a route pattern such as /users/:id or /files/*path into an
+//OpenAPI path template, returning the names of the parameters in order.
+func Path(pattern string) (string, []string) {
+	segments := strings.Split(pattern, "/")
+	params := make([]string, 0, 2)
+	for i, segment := range segments {
+		if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+//JSON renders the document as indented JSON
+func (doc *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+//YAML renders the document as YAML
+func (doc *Document) YAML() ([]byte, error) {
+	return yaml.Marshal(doc)
+}
+
+//Serve adds a GET route that serves the document generated from the composer's
+//routes. The document is rendered as YAML when the pattern ends with .yaml or .yml
+//and JSON otherwise. It's generated from the routes each time they're built, so
+//routes added after calling Serve are included and nothing is built while serving
+//requests. The route itself is hidden.
+func Serve(composer *gonion.Composer, pattern string, config Config) *gonion.RouteOptions {
+	contentType := "application/json"
+	if strings.HasSuffix(pattern, ".yaml") || strings.HasSuffix(pattern, ".yml") {
+		contentType = "application/yaml"
+	}
+	var rendered atomic.Pointer[renderedDocument]
+	composer.AfterBuild(func(routes gonion.Routes) {
+		doc := Generate(routes, config)
+		document := &renderedDocument{}
+		if contentType == "application/yaml" {
+			document.body, document.err = doc.YAML()
+		} else {
+			document.body, document.err = doc.JSON()
+		}
+		rendered.Store(document)
+	})
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		document := rendered.Load()
+		if document == nil || document.err != nil {
+			detail := "The document wasn't generated."
+			if document != nil {
+				detail = document.err.Error()
+			}
+			problem.New(http.StatusInternalServerError).WithDetail(detail).Write(rw, r)
+			return
+		}
+		rw.Header().Set("Content-Type", contentType)
+		rw.Write(document.body)
+	})
+	return composer.Get(pattern, handler).Meta(HiddenKey, true)
+}
+
+type renderedDocument struct {
+	body []byte
+	err  error
+}
@@ -0,0 +1,100 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CoreyKaylor/gonion"
+	"github.com/stretchr/testify/assert"
+)
+
+var noop = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})
+
+func composeAPI() *gonion.Composer {
+	g := gonion.New()
+	g.Get("/", noop)
+	g.Sub("/api", func(api *gonion.Composer) {
+		api.Use().Meta(SecurityKey, SecurityRequirement{"apiKey": {}}).ChainLink(func(inner http.Handler) http.Handler {
+			return inner
+		})
+		api.Get("/users/:id", noop).Meta(OperationKey, &Operation{
+			Summary: "Show a user",
+			Responses: map[string]*Response{
+				"200": {Description: "The user", Content: JSON(Ref("User"))},
+			},
+		})
+		api.Delete("/users/:id", noop).Named("deleteUser")
+		api.Get("/files/*path", noop)
+	})
+	return g
+}
+
+func TestPath_ConvertsParameters(t *testing.T) {
+	path, params := Path("/users/:id/files/*path")
+	assert.Equal(t, path, "/users/{id}/files/{path}")
+	assert.Equal(t, params, []string{"id", "path"})
+}
+
+func TestGenerate_DescribesEveryRoute(t *testing.T) {
+	doc := Generate(composeAPI().BuildRoutes(), Config{Info: Info{Title: "Test", Version: "1.0"}})
+	assert.Equal(t, doc.OpenAPI, "3.1.0")
+	assert.Equal(t, len(doc.Paths), 3)
+
+	show := doc.Paths["/api/users/{id}"].Get
+	assert.Equal(t, show.Summary, "Show a user")
+	assert.Equal(t, show.Parameters[0].Name, "id")
+	assert.Equal(t, show.Parameters[0].In, "path")
+	assert.Equal(t, show.Security, []SecurityRequirement{{"apiKey": {}}})
+	assert.Equal(t, show.Responses["200"].Content["application/json"].Schema.Ref, "#/components/schemas/User")
+
+	assert.Equal(t, doc.Paths["/api/users/{id}"].Delete.OperationID, "deleteUser")
+	assert.Nil(t, doc.Paths["/"].Get.Security)
+}
+
+func TestServe_RendersJSONAndYAML(t *testing.T) {
+	g := composeAPI()
+	Serve(g, "/openapi.json", Config{Info: Info{Title: "Test", Version: "1.0"}})
+	Serve(g, "/openapi.yaml", Config{Info: Info{Title: "Test", Version: "1.0"}})
+	routes := g.BuildRoutes()
+
+	recorder := httptest.NewRecorder()
+	routeFor(routes, "/openapi.json").Handler.ServeHTTP(recorder, new(http.Request))
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/json")
+	var doc Document
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &doc))
+	assert.Equal(t, len(doc.Paths), 3)
+
+	recorder = httptest.NewRecorder()
+	routeFor(routes, "/openapi.yaml").Handler.ServeHTTP(recorder, new(http.Request))
+	assert.Equal(t, recorder.Header().Get("Content-Type"), "application/yaml")
+	assert.True(t, strings.Contains(recorder.Body.String(), "openapi: 3.1.0"))
+	assert.True(t, strings.Contains(recorder.Body.String(), "/api/users/{id}:"))
+}
+
+func TestServe_GeneratesTheDocumentFromTheBuiltRoutes(t *testing.T) {
+	g := composeAPI()
+	built := 0
+	g.BeforeBuild(func(routes []*gonion.RouteModel) {
+		built++
+	})
+	Serve(g, "/openapi.json", Config{Info: Info{Title: "Test", Version: "1.0"}})
+	routes := g.BuildRoutes()
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		routeFor(routes, "/openapi.json").Handler.ServeHTTP(recorder, new(http.Request))
+		assert.Equal(t, recorder.Code, http.StatusOK)
+	}
+	assert.Equal(t, built, 1)
+}
+
+func routeFor(routes gonion.Routes, pattern string) *gonion.Route {
+	for _, route := range routes {
+		if route.Pattern == pattern {
+			return route
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package gonion
+
+import (
+	"strings"
+)
+
+//MatchPattern reports whether the path matches a route pattern and returns the
+//values of its parameters. Patterns use the common router syntax where :name
+//matches a single path segment and *name matches the remainder of the path,
+//including its leading slash.
+func MatchPattern(pattern string, path string) (map[string]string, bool) {
+	patternParts := strings.Split(pattern, "/")
+	pathParts := strings.Split(path, "/")
+	params := make(map[string]string)
+	for i, part := range patternParts {
+		if i >= len(pathParts) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(part, "*"):
+			params[part[1:]] = "/" + strings.Join(pathParts[i:], "/")
+			return params, true
+		case strings.HasPrefix(part, ":"):
+			if pathParts[i] == "" {
+				return nil, false
+			}
+			params[part[1:]] = pathParts[i]
+		case part != pathParts[i]:
+			return nil, false
+		}
+	}
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+	return params, true
+}
@@ -0,0 +1,26 @@
+package gonion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPattern(t *testing.T) {
+	params, ok := MatchPattern("/users/:id/posts/:post", "/users/5/posts/hello")
+	assert.True(t, ok)
+	assert.Equal(t, params, map[string]string{"id": "5", "post": "hello"})
+
+	params, ok = MatchPattern("/files/*path", "/files/a/b.txt")
+	assert.True(t, ok)
+	assert.Equal(t, params, map[string]string{"path": "/a/b.txt"})
+
+	_, ok = MatchPattern("/users/:id", "/users/")
+	assert.False(t, ok)
+	_, ok = MatchPattern("/users/:id", "/users/5/posts")
+	assert.False(t, ok)
+	_, ok = MatchPattern("/users", "/accounts")
+	assert.False(t, ok)
+	_, ok = MatchPattern("/files/*path", "/files")
+	assert.False(t, ok)
+}
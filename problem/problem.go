@@ -0,0 +1,329 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/CoreyKaylor/gonion/negotiation"
+)
+
+const (
+	//ContentTypeJSON is the media type of a JSON problem details document
+	ContentTypeJSON = "application/problem+json"
+	//ContentTypeXML is the media type of an XML problem details document
+	ContentTypeXML = "application/problem+xml"
+	//Namespace is the XML namespace of problem details documents
+	Namespace = "urn:ietf:rfc:7807"
+	//DefaultType is the problem type used when none has been specified
+	DefaultType = "about:blank"
+)
+
+var reserved = map[string]bool{
+	"type":     true,
+	"title":    true,
+	"status":   true,
+	"detail":   true,
+	"instance": true,
+}
+
+//Details is an RFC 9457 problem details document. Extensions are additional
+//members serialized alongside the standard ones.
+type Details struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+//New is a factory method for Details using the default problem type
+//and the standard status text as the title.
+func New(status int) *Details {
+	return &Details{
+		Type:   DefaultType,
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+}
+
+//WithType sets the URI reference identifying the problem type
+func (d *Details) WithType(problemType string) *Details {
+	d.Type = problemType
+	return d
+}
+
+//WithTitle sets the short, human-readable summary of the problem type
+func (d *Details) WithTitle(title string) *Details {
+	d.Title = title
+	return d
+}
+
+//WithDetail sets the human-readable explanation specific to this occurrence
+func (d *Details) WithDetail(detail string) *Details {
+	d.Detail = detail
+	return d
+}
+
+//WithInstance sets the URI reference identifying this occurrence
+func (d *Details) WithInstance(instance string) *Details {
+	d.Instance = instance
+	return d
+}
+
+//With adds an extension member. Names of the standard members are
+//ignored so that extensions can never override them.
+func (d *Details) With(name string, value interface{}) *Details {
+	if reserved[name] {
+		return d
+	}
+	if d.Extensions == nil {
+		d.Extensions = make(map[string]interface{})
+	}
+	d.Extensions[name] = value
+	return d
+}
+
+//Error allows Details to be returned and wrapped as an error
+func (d *Details) Error() string {
+	message := strconv.Itoa(d.Status) + " " + d.Title
+	if d.Detail != "" {
+		message += ": " + d.Detail
+	}
+	return message
+}
+
+//MarshalJSON writes the standard members followed by the extensions
+//as a single flat JSON object.
+func (d *Details) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	member := func(name string, value interface{}) error {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(encoded)
+		return nil
+	}
+	if d.Type != "" {
+		member("type", d.Type)
+	}
+	if d.Title != "" {
+		member("title", d.Title)
+	}
+	if d.Status != 0 {
+		member("status", d.Status)
+	}
+	if d.Detail != "" {
+		member("detail", d.Detail)
+	}
+	if d.Instance != "" {
+		member("instance", d.Instance)
+	}
+	for _, name := range d.extensionNames() {
+		if err := member(name, d.Extensions[name]); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+//UnmarshalJSON reads the standard members and collects everything
+//else into Extensions.
+func (d *Details) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	*d = Details{}
+	for name, raw := range members {
+		var err error
+		switch name {
+		case "type":
+			err = json.Unmarshal(raw, &d.Type)
+		case "title":
+			err = json.Unmarshal(raw, &d.Title)
+		case "status":
+			err = json.Unmarshal(raw, &d.Status)
+		case "detail":
+			err = json.Unmarshal(raw, &d.Detail)
+		case "instance":
+			err = json.Unmarshal(raw, &d.Instance)
+		default:
+			var value interface{}
+			err = json.Unmarshal(raw, &value)
+			d.With(name, value)
+		}
+		if err != nil {
+			return fmt.Errorf("problem: member %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+//MarshalXML writes the document in the format described by appendix B of
+//RFC 9457. Slices are written as repeated <i> elements and maps as nested
+//elements.
+func (d *Details) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "problem"}, Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: Namespace}}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if d.Type != "" {
+		encodeXMLValue(e, "type", d.Type)
+	}
+	if d.Title != "" {
+		encodeXMLValue(e, "title", d.Title)
+	}
+	if d.Status != 0 {
+		encodeXMLValue(e, "status", d.Status)
+	}
+	if d.Detail != "" {
+		encodeXMLValue(e, "detail", d.Detail)
+	}
+	if d.Instance != "" {
+		encodeXMLValue(e, "instance", d.Instance)
+	}
+	for _, name := range d.extensionNames() {
+		if err := encodeXMLValue(e, name, d.Extensions[name]); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func encodeXMLValue(e *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := encodeXMLValue(e, key, v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := encodeXMLValue(e, "i", item); err != nil {
+				return err
+			}
+		}
+	case []string:
+		for _, item := range v {
+			if err := encodeXMLValue(e, "i", item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := e.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func (d *Details) extensionNames() []string {
+	names := make([]string, 0, len(d.Extensions))
+	for name := range d.Extensions {
+		if !reserved[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Write renders the document as XML when the request prefers it and as
+//JSON otherwise, along with the document's status code.
+func (d *Details) Write(rw http.ResponseWriter, r *http.Request) {
+	status := d.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	if prefersXML(r) {
+		body, err := xml.Marshal(d)
+		if err == nil {
+			rw.Header().Set("Content-Type", ContentTypeXML)
+			rw.WriteHeader(status)
+			rw.Write([]byte(xml.Header))
+			rw.Write(body)
+			return
+		}
+	}
+	body, err := json.Marshal(d)
+	if err != nil {
+		body, _ = json.Marshal(New(http.StatusInternalServerError))
+		status = http.StatusInternalServerError
+	}
+	rw.Header().Set("Content-Type", ContentTypeJSON)
+	rw.WriteHeader(status)
+	rw.Write(body)
+}
+
+//Accepted reports whether the request prefers a representation that a problem
+//details document can satisfy over plain text or HTML. Clients that didn't ask
+//for JSON or XML, such as browsers, typically get a plain response instead.
+func Accepted(r *http.Request) bool {
+	if r == nil || r.Header.Get("Accept") == "" {
+		return false
+	}
+	switch negotiation.Negotiate(r, "text/plain", "text/html", ContentTypeJSON, "application/json", ContentTypeXML, "application/xml") {
+	case "", "text/plain", "text/html":
+		return false
+	}
+	return true
+}
+
+func prefersXML(r *http.Request) bool {
+	switch negotiation.Negotiate(r, ContentTypeJSON, ContentTypeXML, "application/json", "application/xml") {
+	case ContentTypeXML, "application/xml":
+		return true
+	}
+	return false
+}
+
+//From converts an error into a problem. Errors that are or wrap *Details are
+//returned as is, anything else becomes an opaque 500 so that internal error
+//messages aren't leaked to clients.
+func From(err error) *Details {
+	var details *Details
+	if errors.As(err, &details) {
+		return details
+	}
+	return New(http.StatusInternalServerError)
+}
+
+//HandlerFunc is an http.Handler that can return an error. A returned error is
+//rendered as a problem details document using From.
+type HandlerFunc func(http.ResponseWriter, *http.Request) error
+
+//ServeHTTP is the implementation of the standard http.Handler interface
+func (h HandlerFunc) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if err := h(rw, r); err != nil {
+		From(err).Write(rw, r)
+	}
+}
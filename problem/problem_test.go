@@ -0,0 +1,101 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUsesStatusTextAsTitle(t *testing.T) {
+	p := New(http.StatusNotFound)
+	assert.Equal(t, p.Type, DefaultType)
+	assert.Equal(t, p.Title, "Not Found")
+	assert.Equal(t, p.Status, http.StatusNotFound)
+}
+
+func TestMarshalJSON_FlattensExtensions(t *testing.T) {
+	p := New(http.StatusForbidden).
+		WithType("https://example.com/probs/out-of-credit").
+		WithDetail("Your current balance is 30").
+		WithInstance("/account/12345").
+		With("balance", 30).
+		With("status", 200)
+	body, err := json.Marshal(p)
+	assert.Nil(t, err)
+	assert.Equal(t, string(body), `{"type":"https://example.com/probs/out-of-credit","title":"Forbidden","status":403,`+
+		`"detail":"Your current balance is 30","instance":"/account/12345","balance":30}`)
+}
+
+func TestUnmarshalJSON_CollectsExtensions(t *testing.T) {
+	p := &Details{}
+	err := json.Unmarshal([]byte(`{"title":"Bad Request","status":400,"invalid":["name"]}`), p)
+	assert.Nil(t, err)
+	assert.Equal(t, p.Status, 400)
+	assert.Equal(t, p.Extensions["invalid"], []interface{}{"name"})
+}
+
+func TestWrite_DefaultsToJSON(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	New(http.StatusBadRequest).Write(recorder, new(http.Request))
+	assert.Equal(t, recorder.Code, http.StatusBadRequest)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), ContentTypeJSON)
+	assert.Equal(t, recorder.Body.String(), `{"type":"about:blank","title":"Bad Request","status":400}`)
+}
+
+func TestWrite_XMLWhenRequested(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Set("Accept", ContentTypeXML)
+	New(http.StatusConflict).With("ids", []interface{}{1, 2}).Write(recorder, request)
+	assert.Equal(t, recorder.Header().Get("Content-Type"), ContentTypeXML)
+	assert.True(t, strings.Contains(recorder.Body.String(),
+		`<problem xmlns="urn:ietf:rfc:7807"><type>about:blank</type><title>Conflict</title><status>409</status><ids><i>1</i><i>2</i></ids></problem>`))
+}
+
+func TestWrite_NegotiatesXMLByMediaTypeAndQuality(t *testing.T) {
+	for accept, contentType := range map[string]string{
+		"application/xhtml+xml":                           ContentTypeJSON,
+		"application/json;q=0.5, application/xml":         ContentTypeXML,
+		"application/problem+json, application/xml;q=0.9": ContentTypeJSON,
+	} {
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.Header.Set("Accept", accept)
+		New(http.StatusConflict).Write(recorder, request)
+		assert.Equal(t, recorder.Header().Get("Content-Type"), contentType, accept)
+	}
+}
+
+func TestAccepted_IsFalseForBrowsers(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	assert.False(t, Accepted(request))
+	request.Header.Set("Accept", "application/problem+json")
+	assert.True(t, Accepted(request))
+}
+
+func TestHandlerFunc_RendersReturnedProblem(t *testing.T) {
+	handler := HandlerFunc(func(rw http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("loading user: %w", New(http.StatusNotFound).WithDetail("no such user"))
+	})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, new(http.Request))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+	assert.True(t, strings.Contains(recorder.Body.String(), `"detail":"no such user"`))
+}
+
+func TestHandlerFunc_HidesPlainErrors(t *testing.T) {
+	handler := HandlerFunc(func(rw http.ResponseWriter, r *http.Request) error {
+		return errors.New("connection refused")
+	})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, new(http.Request))
+	assert.Equal(t, recorder.Code, http.StatusInternalServerError)
+	assert.False(t, strings.Contains(recorder.Body.String(), "connection refused"))
+}
@@ -0,0 +1,96 @@
+package gonion
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+//Register adds a ChainLink to the composer's registry of named middleware so
+//that it can be referred to by name, such as from configuration.
+func (composer *Composer) Register(name string, link func(http.Handler) http.Handler) {
+	composer.settings.chainLinks[name] = ChainLink(link)
+}
+
+//RegisterHandler adds a handler to the composer's registry of named handlers so
+//that routes can refer to it by name.
+func (composer *Composer) RegisterHandler(name string, handler http.Handler) {
+	composer.settings.handlers[name] = handler
+}
+
+//Registered uses the ChainLink registered with the name. It's looked up while
+//building the routes, so it can be registered before or after this is called.
+func (mo *MiddlewareOptions) Registered(name string) {
+	settings := mo.composer.settings
+	mo.ref = name
+	mo.Named(name).RouteChainLink(func(*RouteModel) ChainLink {
+		return settings.chainLinks[name]
+	})
+}
+
+//HandleRegistered adds a route for the specified method and pattern using the handler
+//registered with the name. It's looked up while building the routes, so it can be
+//registered before or after this is called.
+func (composer *Composer) HandleRegistered(method string, pattern string, name string) *RouteOptions {
+	options := composer.Handle(method, pattern, nil)
+	options.route.ref = name
+	return options
+}
+
+//Skip excludes the middleware with the name, given by Named or Registered, from
+//the routes under the composer's path.
+func (composer *Composer) Skip(name string) {
+	composer.skipWhen(name, func(*RouteModel) bool {
+		return true
+	})
+}
+
+//Skip excludes the middleware with the name, given by Named or Registered, from
+//the routes matching the constraint.
+func (rc *RouteConstraint) Skip(name string) {
+	rc.composer.skipWhen(name, rc.routeFilter)
+}
+
+func (composer *Composer) skipWhen(name string, routeFilter func(*RouteModel) bool) {
+	registry := composer.middlewareRegistry
+	registry.skips = append(registry.skips, &skip{
+		filter: composer.scoped(routeFilter),
+		name:   name,
+	})
+}
+
+//validate resolves the registered handlers of routes and reports every name that
+//doesn't refer to something registered, along with the context keys routes require
+//that aren't provided.
+func (composer *Composer) validate() error {
+	var errs []error
+	names := make(map[string]bool)
+	for _, middle := range composer.middlewareRegistry.middleware {
+		names[middle.name] = true
+		if _, ok := composer.settings.chainLinks[middle.ref]; middle.ref != "" && !ok {
+			errs = append(errs, fmt.Errorf("gonion: middleware %q is not registered", middle.ref))
+		}
+	}
+	for _, skip := range composer.middlewareRegistry.skips {
+		if !names[skip.name] {
+			errs = append(errs, fmt.Errorf("gonion: skipped middleware %q is not used", skip.name))
+		}
+	}
+	for _, route := range composer.routeRegistry.routes {
+		if len(route.requires) > 0 {
+			for _, key := range unprovided(route, composer.middlewareRegistry.middlewareFor(route)) {
+				errs = append(errs, fmt.Errorf("gonion: route %s %s requires %q which no middleware provides", route.Method, route.Pattern, key))
+			}
+		}
+		if route.ref == "" {
+			continue
+		}
+		handler, ok := composer.settings.handlers[route.ref]
+		if !ok {
+			errs = append(errs, fmt.Errorf("gonion: route %s %s uses handler %q which is not registered", route.Method, route.Pattern, route.ref))
+			continue
+		}
+		route.Handler = handler
+	}
+	return errors.Join(errs...)
+}
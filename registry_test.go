@@ -0,0 +1,68 @@
+package gonion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writes(text string) func(http.Handler) http.Handler {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Write([]byte(text))
+			inner.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func TestRegistry_ResolvesNamesWhileBuilding(t *testing.T) {
+	g := oneOfEachRoute()
+	g.Use().Registered("auth")
+	g.HandleRegistered("GET", "/health", "health")
+	g.Register("auth", writes("auth->"))
+	g.RegisterHandler("health", http.HandlerFunc(getIndex2))
+	assertRouteConstraintResponse(t, g, "GET", "auth->GET")
+	routes, err := g.Build()
+	assert.Nil(t, err)
+	assert.NotNil(t, routes.routeFor("GET", "/health").Handler)
+}
+
+func TestRegistry_ReportsUnregisteredNames(t *testing.T) {
+	g := oneOfEachRoute()
+	g.Use().Registered("auth")
+	g.HandleRegistered("GET", "/health", "health")
+	g.Skip("ratelimit")
+	_, err := g.Build()
+	assert.Equal(t, err.Error(), `gonion: middleware "auth" is not registered
+gonion: skipped middleware "ratelimit" is not used
+gonion: route GET /health uses handler "health" which is not registered`)
+	assert.Panics(t, func() {
+		g.BuildRoutes()
+	})
+}
+
+func TestSkip_ExcludesNamedMiddleware(t *testing.T) {
+	g := New()
+	g.Register("auth", writes("auth->"))
+	g.Use().Registered("auth")
+	g.Use().Named("audit").ChainLink(writes("audit->"))
+	g.Get("/", http.HandlerFunc(getIndex2))
+	g.Sub("/internal", func(internal *Composer) {
+		internal.Skip("auth")
+		internal.Get("/", http.HandlerFunc(getIndex2))
+		internal.Post("/", http.HandlerFunc(getIndex2))
+		internal.Only().Post().Skip("audit")
+	})
+	routes := g.BuildRoutes()
+	assert.Equal(t, serveRoute(routes.routeFor("GET", "/")), "auth->audit->Success!")
+	assert.Equal(t, serveRoute(routes.routeFor("GET", "/internal/")), "audit->Success!")
+	assert.Equal(t, serveRoute(routes.routeFor("POST", "/internal/")), "Success!")
+}
+
+func serveRoute(route *Route) string {
+	recorder := httptest.NewRecorder()
+	route.Handler.ServeHTTP(recorder, new(http.Request))
+	return recorder.Body.String()
+}
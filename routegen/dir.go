@@ -0,0 +1,47 @@
+package routegen
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//Dir reads the route registrations of the Go package in the directory and writes
+//the generated helpers to output in the same directory. Test files and the output
+//itself are ignored.
+func Dir(dir string, output string) error {
+	fset := token.NewFileSet()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	var files []*ast.File
+	pkg := ""
+	for _, match := range matches {
+		if strings.HasSuffix(match, "_test.go") || filepath.Base(match) == filepath.Base(output) {
+			continue
+		}
+		file, err := parser.ParseFile(fset, match, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		pkg = file.Name.Name
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return errors.New("routegen: no Go files in " + dir)
+	}
+	routes, err := Extract(fset, files)
+	if err != nil {
+		return err
+	}
+	source, err := Generate(pkg, routes)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, filepath.Base(output)), source, 0644)
+}
@@ -0,0 +1,367 @@
+package routegen
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+const gonionPath = "github.com/CoreyKaylor/gonion"
+
+//Route is a route registration read from source
+type Route struct {
+	Method   string
+	Pattern  string
+	Name     string
+	Produces bool
+	Position token.Position
+}
+
+var routeMethods = map[string]string{
+	"Get": "GET", "Post": "POST", "Put": "PUT", "Patch": "PATCH", "Delete": "DELETE",
+}
+
+var optionMethods = map[string]bool{
+	"Meta": true, "Named": true, "Produces": true, "Requires": true,
+}
+
+type extractor struct {
+	fset     *token.FileSet
+	consts   map[string]string
+	funcs    map[string]*funcDecl
+	walking  map[string]bool
+	gonion   string
+	names    map[*ast.CallExpr]string
+	produces map[*ast.CallExpr]bool
+	routes   []*Route
+	errs     []error
+}
+
+type funcDecl struct {
+	decl   *ast.FuncDecl
+	gonion string
+}
+
+//Extract statically reads the route registrations of a package's files. Composers
+//are followed from gonion.New() and *gonion.Composer parameters through Sub and
+//calls to the package's funcs, so patterns must be string literals or constants.
+//Funcs taking a composer that aren't called within the package are read as if the
+//composer has no path prefix.
+func Extract(fset *token.FileSet, files []*ast.File) ([]*Route, error) {
+	e := &extractor{
+		fset:     fset,
+		consts:   make(map[string]string),
+		funcs:    make(map[string]*funcDecl),
+		walking:  make(map[string]bool),
+		names:    make(map[*ast.CallExpr]string),
+		produces: make(map[*ast.CallExpr]bool),
+	}
+	called := make(map[string]bool)
+	var order []string
+	for _, file := range files {
+		e.collectConsts(file)
+		gonion := importName(file)
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Body != nil && gonion != "" {
+				e.funcs[fn.Name.Name] = &funcDecl{decl: fn, gonion: gonion}
+				order = append(order, fn.Name.Name)
+			}
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			if call, ok := node.(*ast.CallExpr); ok {
+				if ident, ok := call.Fun.(*ast.Ident); ok {
+					called[ident.Name] = true
+				}
+			}
+			return true
+		})
+	}
+	for _, name := range order {
+		fn := e.funcs[name]
+		env := make(map[string]string)
+		e.gonion = fn.gonion
+		e.composerParams(fn.decl.Type, env, "")
+		if len(env) > 0 && called[name] {
+			continue
+		}
+		e.walkFunc(name, env)
+	}
+	return e.routes, errors.Join(e.errs...)
+}
+
+//walkFunc walks the body of one of the package's funcs, guarding against recursion
+func (e *extractor) walkFunc(name string, env map[string]string) {
+	if e.walking[name] {
+		return
+	}
+	e.walking[name] = true
+	defer delete(e.walking, name)
+	fn := e.funcs[name]
+	previous := e.gonion
+	e.gonion = fn.gonion
+	e.walk(fn.decl.Body, env)
+	e.gonion = previous
+}
+
+//callFunc follows a call to one of the package's funcs that is passed a composer
+func (e *extractor) callFunc(call *ast.CallExpr, name string, env map[string]string) {
+	fn, ok := e.funcs[name]
+	if !ok {
+		return
+	}
+	fnEnv := make(map[string]string)
+	i := 0
+	for _, field := range fn.decl.Type.Params.List {
+		names := field.Names
+		if len(names) == 0 {
+			i++
+			continue
+		}
+		for _, param := range names {
+			if i < len(call.Args) {
+				if arg, ok := call.Args[i].(*ast.Ident); ok {
+					if prefix, ok := env[arg.Name]; ok {
+						fnEnv[param.Name] = prefix
+					}
+				}
+			}
+			i++
+		}
+	}
+	if len(fnEnv) > 0 {
+		e.walkFunc(name, fnEnv)
+	}
+}
+
+func importName(file *ast.File) string {
+	for _, spec := range file.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path == gonionPath {
+			if spec.Name != nil {
+				return spec.Name.Name
+			}
+			return "gonion"
+		}
+	}
+	return ""
+}
+
+func (e *extractor) collectConsts(file *ast.File) {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			for i, name := range value.Names {
+				if i < len(value.Values) {
+					if s, ok := e.stringValue(value.Values[i]); ok {
+						e.consts[name.Name] = s
+					}
+				}
+			}
+		}
+	}
+}
+
+//composerParams adds the *gonion.Composer parameters of a func to the environment
+func (e *extractor) composerParams(fn *ast.FuncType, env map[string]string, prefix string) {
+	for _, field := range fn.Params.List {
+		star, ok := field.Type.(*ast.StarExpr)
+		if !ok || !e.isGonion(star.X, "Composer") {
+			continue
+		}
+		for _, name := range field.Names {
+			env[name.Name] = prefix
+		}
+	}
+}
+
+func (e *extractor) isGonion(expr ast.Expr, name string) bool {
+	selector, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := selector.X.(*ast.Ident)
+	return ok && pkg.Name == e.gonion && selector.Sel.Name == name
+}
+
+//walk inspects the statements of a func, where env maps the names of composer
+//variables in scope to their path prefix.
+func (e *extractor) walk(body ast.Node, env map[string]string) {
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			for i, lhs := range node.Lhs {
+				if i < len(node.Rhs) {
+					e.assign(lhs, node.Rhs[i], env)
+				}
+			}
+		case *ast.ValueSpec:
+			for i, name := range node.Names {
+				if i < len(node.Values) {
+					e.assign(name, node.Values[i], env)
+				}
+			}
+		case *ast.CallExpr:
+			return e.call(node, env)
+		}
+		return true
+	})
+}
+
+func (e *extractor) assign(lhs ast.Expr, rhs ast.Expr, env map[string]string) {
+	name, ok := lhs.(*ast.Ident)
+	if !ok {
+		return
+	}
+	switch rhs := rhs.(type) {
+	case *ast.CallExpr:
+		if e.isGonion(rhs.Fun, "New") {
+			env[name.Name] = ""
+		}
+	case *ast.Ident:
+		if prefix, ok := env[rhs.Name]; ok {
+			env[name.Name] = prefix
+		}
+	}
+}
+
+func (e *extractor) call(call *ast.CallExpr, env map[string]string) bool {
+	if ident, ok := call.Fun.(*ast.Ident); ok {
+		e.callFunc(call, ident.Name, env)
+		return true
+	}
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return true
+	}
+	if optionMethods[selector.Sel.Name] {
+		e.option(call, selector)
+		return true
+	}
+	receiver, ok := selector.X.(*ast.Ident)
+	if !ok {
+		return true
+	}
+	prefix, ok := env[receiver.Name]
+	if !ok {
+		return true
+	}
+	switch method := selector.Sel.Name; {
+	case method == "Sub":
+		e.sub(call, env, prefix)
+		return false
+	case routeMethods[method] != "":
+		e.route(call, routeMethods[method], prefix, 0)
+	case method == "Handle" || method == "HandleRegistered" || method == "HandleContextual":
+		if len(call.Args) < 2 {
+			return true
+		}
+		httpMethod, ok := e.stringValue(call.Args[0])
+		if !ok {
+			e.errorf(call.Args[0], "%s method must be a string literal or constant", method)
+			return true
+		}
+		e.route(call, httpMethod, prefix, 1)
+	}
+	return true
+}
+
+//option records the name given to a route, and whether it produces a media type,
+//for the route registration at the start of the chain of route options.
+func (e *extractor) option(call *ast.CallExpr, selector *ast.SelectorExpr) {
+	route := selector.X
+	for {
+		inner, ok := route.(*ast.CallExpr)
+		if !ok {
+			return
+		}
+		innerSelector, ok := inner.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return
+		}
+		if !optionMethods[innerSelector.Sel.Name] {
+			switch selector.Sel.Name {
+			case "Named":
+				if len(call.Args) == 1 {
+					if name, ok := e.stringValue(call.Args[0]); ok {
+						e.names[inner] = name
+					}
+				}
+			case "Produces":
+				e.produces[inner] = true
+			}
+			return
+		}
+		route = innerSelector.X
+	}
+}
+
+func (e *extractor) sub(call *ast.CallExpr, env map[string]string, prefix string) {
+	if len(call.Args) != 2 {
+		return
+	}
+	pattern, ok := e.stringValue(call.Args[0])
+	if !ok {
+		e.errorf(call.Args[0], "Sub pattern must be a string literal or constant")
+		return
+	}
+	lit, ok := call.Args[1].(*ast.FuncLit)
+	if !ok {
+		e.errorf(call.Args[1], "Sub func must be a func literal")
+		return
+	}
+	subEnv := make(map[string]string, len(env)+1)
+	for name, value := range env {
+		subEnv[name] = value
+	}
+	e.composerParams(lit.Type, subEnv, prefix+pattern)
+	e.walk(lit.Body, subEnv)
+}
+
+func (e *extractor) route(call *ast.CallExpr, method string, prefix string, patternIndex int) {
+	if len(call.Args) <= patternIndex {
+		return
+	}
+	pattern, ok := e.stringValue(call.Args[patternIndex])
+	if !ok {
+		e.errorf(call.Args[patternIndex], "pattern must be a string literal or constant")
+		return
+	}
+	e.routes = append(e.routes, &Route{
+		Method:   method,
+		Pattern:  prefix + pattern,
+		Name:     e.names[call],
+		Produces: e.produces[call],
+		Position: e.fset.Position(call.Pos()),
+	})
+}
+
+func (e *extractor) stringValue(expr ast.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		if expr.Kind == token.STRING {
+			value, err := strconv.Unquote(expr.Value)
+			return value, err == nil
+		}
+	case *ast.Ident:
+		value, ok := e.consts[expr.Name]
+		return value, ok
+	case *ast.BinaryExpr:
+		if expr.Op == token.ADD {
+			left, ok := e.stringValue(expr.X)
+			right, ok2 := e.stringValue(expr.Y)
+			return left + right, ok && ok2
+		}
+	case *ast.ParenExpr:
+		return e.stringValue(expr.X)
+	}
+	return "", false
+}
+
+func (e *extractor) errorf(node ast.Node, format string, args ...interface{}) {
+	e.errs = append(e.errs, fmt.Errorf("%s: %s", e.fset.Position(node.Pos()), fmt.Sprintf(format, args...)))
+}
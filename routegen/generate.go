@@ -0,0 +1,279 @@
+package routegen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+//helper is a generated URL helper and client method for a single route
+type helper struct {
+	Route  *Route
+	Name   string
+	Params []param
+	Path   string
+	Body   bool
+}
+
+type param struct {
+	Name     string
+	CatchAll bool
+}
+
+//Generate renders Go source with a URL helper for each route, named URL<Name>, and a
+//RouteClient with a method per route. Routes are named by Named in source or otherwise
+//by their pattern and method. Generation fails when routes are ambiguous, meaning the
+//same method and pattern is registered twice, patterns only differ by parameter names,
+//or two routes end up with the same name.
+func Generate(pkg string, routes []*Route) ([]byte, error) {
+	helpers, err := helpersFor(routes)
+	if err != nil {
+		return nil, err
+	}
+	data := struct {
+		Package  string
+		Helpers  []*helper
+		CatchAll bool
+	}{Package: pkg, Helpers: helpers}
+	for _, h := range helpers {
+		for _, p := range h.Params {
+			data.CatchAll = data.CatchAll || p.CatchAll
+		}
+	}
+	var buf bytes.Buffer
+	if err := generated.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func helpersFor(routes []*Route) ([]*helper, error) {
+	var errs []error
+	byShape := make(map[string]*Route)
+	byName := make(map[string]*Route)
+	helpers := make([]*helper, 0, len(routes))
+	for _, route := range routes {
+		shape := route.Method + " " + shapeOf(route.Pattern)
+		if existing, ok := byShape[shape]; ok {
+			if existing.Pattern == route.Pattern && existing.Produces && route.Produces {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s: %s %s is ambiguous with %s %s at %s",
+				route.Position, route.Method, route.Pattern, existing.Method, existing.Pattern, existing.Position))
+			continue
+		}
+		byShape[shape] = route
+		h := newHelper(route)
+		if existing, ok := byName[h.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: %s %s and %s %s at %s are both named %s; name one of them with Named",
+				route.Position, route.Method, route.Pattern, existing.Method, existing.Pattern, existing.Position, h.Name))
+			continue
+		}
+		byName[h.Name] = route
+		helpers = append(helpers, h)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	sort.Slice(helpers, func(i, j int) bool {
+		return helpers[i].Name < helpers[j].Name
+	})
+	return helpers, nil
+}
+
+//shapeOf replaces parameter names so that patterns a router can't tell apart compare equal
+func shapeOf(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = segment[:1]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func newHelper(route *Route) *helper {
+	h := &helper{
+		Route: route,
+		Body:  route.Method == "POST" || route.Method == "PUT" || route.Method == "PATCH",
+	}
+	var statics []string
+	var path []string
+	literal := ""
+	segments := strings.Split(route.Pattern, "/")
+	for i, segment := range segments {
+		if i > 0 {
+			literal += "/"
+		}
+		switch {
+		case strings.HasPrefix(segment, ":"), strings.HasPrefix(segment, "*"):
+			p := param{Name: identifier(segment[1:], false), CatchAll: segment[0] == '*'}
+			if reserved[p.Name] {
+				p.Name += "Param"
+			}
+			h.Params = append(h.Params, p)
+			if literal != "" {
+				path = append(path, strconv.Quote(literal))
+				literal = ""
+			}
+			if p.CatchAll {
+				path = append(path, "escapeCatchAll("+p.Name+")")
+			} else {
+				path = append(path, "url.PathEscape("+p.Name+")")
+			}
+		default:
+			literal += segment
+			if segment != "" {
+				statics = append(statics, segment)
+			}
+		}
+	}
+	if literal != "" || len(path) == 0 {
+		path = append(path, strconv.Quote(literal))
+	}
+	h.Path = strings.Join(path, " + ")
+	if route.Name != "" {
+		h.Name = identifier(route.Name, true)
+	} else {
+		last := segments[len(segments)-1]
+		member := strings.HasPrefix(last, ":") || strings.HasPrefix(last, "*")
+		h.Name = identifier(strings.Join(statics, "_"), true) + action(route, member)
+	}
+	return h
+}
+
+//reserved are the identifiers of the generated code that parameters would hide
+//or redeclare
+var reserved = map[string]bool{
+	"context": true, "io": true, "http": true, "url": true, "strings": true,
+	"c": true, "ctx": true, "query": true, "body": true, "contentType": true,
+}
+
+//action names what a route does the way resourceful routes commonly are
+func action(route *Route, member bool) string {
+	switch route.Method {
+	case "GET":
+		if member {
+			return "Show"
+		}
+		return "Index"
+	case "POST":
+		return "Create"
+	case "PUT", "PATCH":
+		return "Update"
+	case "DELETE":
+		return "Destroy"
+	}
+	return identifier(strings.ToLower(route.Method), true)
+}
+
+var initialisms = map[string]string{
+	"api": "API", "id": "ID", "url": "URL", "uri": "URI", "http": "HTTP",
+	"json": "JSON", "xml": "XML", "html": "HTML", "uuid": "UUID",
+}
+
+//identifier converts a name such as user_id, users.show or admin-users into a
+//Go identifier, exported or not.
+func identifier(name string, exported bool) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for i, word := range words {
+		if upper, ok := initialisms[strings.ToLower(word)]; ok && (exported || i > 0) {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		if exported || i > 0 {
+			runes[0] = unicode.ToUpper(runes[0])
+		} else {
+			runes[0] = unicode.ToLower(runes[0])
+		}
+		b.WriteString(string(runes))
+	}
+	ident := b.String()
+	if ident == "" {
+		ident = "Root"
+		if !exported {
+			ident = "root"
+		}
+	}
+	if unicode.IsDigit([]rune(ident)[0]) {
+		ident = "R" + ident
+	}
+	if token.IsKeyword(ident) {
+		ident += "Param"
+	}
+	return ident
+}
+
+var generated = template.Must(template.New("routes").Parse(`// Code generated by gonionroutes. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	{{- if .CatchAll}}
+	"strings"
+	{{- end}}
+)
+{{range .Helpers}}
+// URL{{.Name}} returns the path of {{.Route.Method}} {{.Route.Pattern}}
+func URL{{.Name}}({{range $i, $p := .Params}}{{if $i}}, {{end}}{{$p.Name}}{{end}}{{if .Params}} string{{end}}) string {
+	return {{.Path}}
+}
+{{end}}
+// RouteClient sends requests to the routes of a server over HTTP
+type RouteClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewRouteClient creates a RouteClient for the server at baseURL, using
+// http.DefaultClient when httpClient is nil
+func NewRouteClient(baseURL string, httpClient *http.Client) *RouteClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &RouteClient{BaseURL: baseURL, HTTPClient: httpClient}
+}
+{{range .Helpers}}
+// {{.Name}} sends {{.Route.Method}} {{.Route.Pattern}}
+func (c *RouteClient) {{.Name}}(ctx context.Context{{range .Params}}, {{.Name}} string{{end}}, query url.Values{{if .Body}}, body io.Reader, contentType string{{end}}) (*http.Response, error) {
+	return c.do(ctx, {{printf "%q" .Route.Method}}, URL{{.Name}}({{range $i, $p := .Params}}{{if $i}}, {{end}}{{$p.Name}}{{end}}), query, {{if .Body}}body, contentType{{else}}nil, ""{{end}})
+}
+{{end}}
+func (c *RouteClient) do(ctx context.Context, method string, path string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	return c.HTTPClient.Do(request)
+}
+{{if .CatchAll}}
+func escapeCatchAll(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+{{end}}`))
@@ -0,0 +1,143 @@
+package routegen
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const source = `package app
+
+import (
+	"net/http"
+
+	g "github.com/CoreyKaylor/gonion"
+)
+
+const apiPrefix = "/api"
+
+func Routes() *g.Composer {
+	composer := g.New()
+	composer.Get("/", index)
+	composer.Sub(apiPrefix, func(api *g.Composer) {
+		users(api)
+		api.Get("/files/*path", index)
+	})
+	return composer
+}
+
+func users(api *g.Composer) {
+	api.Sub("/users", func(users *g.Composer) {
+		users.Get("", index)
+		users.Post("", index)
+		users.Get("/:user_id", index).Produces("application/json")
+		users.Get("/:user_id", index).Produces("text/html")
+		users.Handle("DELETE", "/:user_id", index).Named("users.remove")
+	})
+}
+
+func index(rw http.ResponseWriter, r *http.Request) {}
+`
+
+func parse(t *testing.T, sources ...string) (*token.FileSet, []*ast.File) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, src := range sources {
+		file, err := parser.ParseFile(fset, "app.go", src, 0)
+		assert.Nil(t, err)
+		files = append(files, file)
+	}
+	return fset, files
+}
+
+func TestExtract_FollowsSubsAndParameters(t *testing.T) {
+	fset, files := parse(t, source)
+	routes, err := Extract(fset, files)
+	assert.Nil(t, err)
+	var found []string
+	for _, route := range routes {
+		found = append(found, route.Method+" "+route.Pattern+" "+route.Name)
+	}
+	assert.Equal(t, found, []string{
+		"GET / ",
+		"GET /api/users ",
+		"POST /api/users ",
+		"GET /api/users/:user_id ",
+		"GET /api/users/:user_id ",
+		"DELETE /api/users/:user_id users.remove",
+		"GET /api/files/*path ",
+	})
+}
+
+func TestGenerate_TypedHelpers(t *testing.T) {
+	fset, files := parse(t, source)
+	routes, _ := Extract(fset, files)
+	generated, err := Generate("app", routes)
+	assert.Nil(t, err)
+	code := string(generated)
+	assert.True(t, strings.HasPrefix(code, "// Code generated by gonionroutes. DO NOT EDIT."))
+	assert.True(t, strings.Contains(code, "func URLAPIUsersShow(userID string) string {\n\treturn \"/api/users/\" + url.PathEscape(userID)\n}"))
+	assert.True(t, strings.Contains(code, "func URLUsersRemove(userID string) string"))
+	assert.True(t, strings.Contains(code, "func URLAPIUsersIndex() string {\n\treturn \"/api/users\"\n}"))
+	assert.True(t, strings.Contains(code, "func URLRootIndex() string"))
+	assert.True(t, strings.Contains(code, `return "/api/files/" + escapeCatchAll(path)`))
+	assert.True(t, strings.Contains(code,
+		"func (c *RouteClient) APIUsersCreate(ctx context.Context, query url.Values, body io.Reader, contentType string) (*http.Response, error)"))
+	assert.True(t, strings.Contains(code,
+		"func (c *RouteClient) APIUsersShow(ctx context.Context, userID string, query url.Values) (*http.Response, error)"))
+}
+
+func TestGenerate_FailsOnAmbiguousRoutes(t *testing.T) {
+	fset, files := parse(t, `package app
+
+import "github.com/CoreyKaylor/gonion"
+
+func Routes(g *gonion.Composer) {
+	g.Get("/users/:id", nil)
+	g.Get("/users/:name", nil)
+	g.Put("/users/:id", nil)
+	g.Put("/users/:id/profile", nil).Named("users.update")
+}
+`)
+	routes, err := Extract(fset, files)
+	assert.Nil(t, err)
+	_, err = Generate("app", routes)
+	assert.Equal(t, err.Error(), "app.go:7:2: GET /users/:name is ambiguous with GET /users/:id at app.go:6:2\n"+
+		"app.go:9:2: PUT /users/:id/profile and PUT /users/:id at app.go:8:2 are both named UsersUpdate; name one of them with Named")
+}
+
+func TestExtract_RequiresStaticPatterns(t *testing.T) {
+	fset, files := parse(t, `package app
+
+import "github.com/CoreyKaylor/gonion"
+
+func Routes(g *gonion.Composer, pattern string) {
+	g.Get(pattern, nil)
+}
+`)
+	_, err := Extract(fset, files)
+	assert.Equal(t, err.Error(), "app.go:6:8: pattern must be a string literal or constant")
+}
+
+func TestGenerate_RenamesParametersThatCollideWithTheGeneratedCode(t *testing.T) {
+	routes := []*Route{{Method: "GET", Pattern: "/files/*strings"}}
+	for _, name := range []string{"context", "io", "http", "url", "strings", "c", "ctx", "query", "body", "contentType"} {
+		routes = append(routes, &Route{Method: "POST", Pattern: "/" + name + "/:" + name})
+	}
+	generated, err := Generate("app", routes)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(generated), "func URLURLCreate(urlParam string) string {\n\treturn \"/url/\" + url.PathEscape(urlParam)\n}"))
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "routes.go", generated, 0)
+	assert.Nil(t, err)
+	config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = config.Check("app", fset, []*ast.File{file}, nil)
+	assert.Nil(t, err)
+}
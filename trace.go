@@ -0,0 +1,236 @@
+package gonion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CoreyKaylor/gonion/writer"
+)
+
+//Tracer is the opt-in debug mode that times every layer of the handler chain.
+//Each request gets a Trace in its context and a Server-Timing header with the
+//timings until the response started, the final timings follow as a trailer for
+//clients that read them, and the Tracer aggregates the timings per route and layer.
+type Tracer struct {
+	mutex sync.Mutex
+	stats map[layerKey]*LayerReport
+}
+
+type layerKey struct {
+	route    string
+	position int
+}
+
+//EnableTracing turns on tracing for the routes built afterwards. It applies to
+//the composer and all of its Sub composers. When tracing isn't enabled the
+//built chain is exactly the same as without tracing.
+func (composer *Composer) EnableTracing() *Tracer {
+	if composer.settings.tracer == nil {
+		composer.settings.tracer = &Tracer{
+			stats: make(map[layerKey]*LayerReport),
+		}
+	}
+	return composer.settings.tracer
+}
+
+//Span is the time spent in a single layer of the handler chain, including
+//the layers it wraps.
+type Span struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+//Duration is the total time spent in the layer
+func (s Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+//Trace is the timing of each layer of the handler chain for a single request.
+//Spans are in the order the layers were entered, so each span wraps the next.
+type Trace struct {
+	Route string
+	Spans []Span
+}
+
+//Self is the time spent in the layer at the position excluding the layers it wraps
+func (t *Trace) Self(position int) time.Duration {
+	self := t.Spans[position].Duration()
+	if position+1 < len(t.Spans) {
+		self -= t.Spans[position+1].Duration()
+	}
+	return self
+}
+
+type traceKey struct{}
+
+//TraceFrom returns the trace of the request, or nil when tracing isn't enabled
+func TraceFrom(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey{}).(*Trace)
+	return trace
+}
+
+//ForkTrace is for middleware that runs the rest of the chain in a goroutine that
+//can outlive the request, such as a timeout. The goroutine traces its layers on a
+//copy of the request's trace in the returned context, and join adds them back to
+//the request's trace. Only call join once the goroutine is done, a goroutine that's
+//abandoned is never joined so it can't race with the trace being reported.
+func ForkTrace(ctx context.Context) (forked context.Context, join func()) {
+	trace := TraceFrom(ctx)
+	if trace == nil {
+		return ctx, noJoin
+	}
+	fork := &Trace{Route: trace.Route, Spans: append(make([]Span, 0, cap(trace.Spans)), trace.Spans...)}
+	return context.WithValue(ctx, traceKey{}, fork), func() {
+		trace.Spans = fork.Spans
+	}
+}
+
+func noJoin() {}
+
+func (t *Tracer) build(route *RouteModel, middleware []*middleware) http.Handler {
+	chain := t.layer("handler", route.Handler)
+	for i := len(middleware) - 1; i >= 0; i-- {
+		chain = t.layer(middleware[i].name, middleware[i].handler(route)(chain))
+	}
+	name := route.Method + " " + route.Pattern
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		trace := &Trace{Route: name, Spans: make([]Span, 0, len(middleware)+1)}
+		r = r.WithContext(context.WithValue(r.Context(), traceKey{}, trace))
+		w := writer.Wrap(rw)
+		w.BeforeCommit(func() {
+			w.Header().Set("Server-Timing", serverTiming(trace, time.Now()))
+		})
+		chain.ServeHTTP(w.ResponseWriter(), r)
+		w.Release()
+		rw.Header().Set(http.TrailerPrefix+"Server-Timing", serverTiming(trace, time.Now()))
+		t.record(trace)
+	})
+}
+
+func (t *Tracer) layer(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		trace := TraceFrom(r.Context())
+		if trace == nil {
+			next.ServeHTTP(rw, r)
+			return
+		}
+		position := len(trace.Spans)
+		trace.Spans = append(trace.Spans, Span{Name: name, Start: time.Now()})
+		next.ServeHTTP(rw, r)
+		trace.Spans[position].End = time.Now()
+	})
+}
+
+//serverTiming describes the time spent in each layer, counting the layers that
+//haven't returned yet as ending now
+func serverTiming(trace *Trace, now time.Time) string {
+	metrics := make([]string, len(trace.Spans))
+	end := func(span Span) time.Time {
+		if span.End.IsZero() {
+			return now
+		}
+		return span.End
+	}
+	for i, span := range trace.Spans {
+		self := end(span).Sub(span.Start)
+		if i+1 < len(trace.Spans) {
+			self -= end(trace.Spans[i+1]).Sub(trace.Spans[i+1].Start)
+		}
+		duration := float64(self) / float64(time.Millisecond)
+		metrics[i] = fmt.Sprintf("l%d;dur=%s;desc=%s", i, strconv.FormatFloat(duration, 'f', 3, 64), strconv.Quote(span.Name))
+	}
+	return strings.Join(metrics, ", ")
+}
+
+//LayerReport is the aggregated timing of a single layer of a route
+type LayerReport struct {
+	Route    string
+	Position int
+	Layer    string
+	Count    int
+	Total    time.Duration
+	Self     time.Duration
+	Max      time.Duration
+}
+
+//Mean is the average time spent in the layer excluding the layers it wraps
+func (l LayerReport) Mean() time.Duration {
+	if l.Count == 0 {
+		return 0
+	}
+	return l.Self / time.Duration(l.Count)
+}
+
+func (t *Tracer) record(trace *Trace) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for i, span := range trace.Spans {
+		key := layerKey{trace.Route, i}
+		stats, ok := t.stats[key]
+		if !ok {
+			stats = &LayerReport{Route: trace.Route, Position: i, Layer: span.Name}
+			t.stats[key] = stats
+		}
+		self := trace.Self(i)
+		stats.Count++
+		stats.Total += span.Duration()
+		stats.Self += self
+		if self > stats.Max {
+			stats.Max = self
+		}
+	}
+}
+
+//Report returns the aggregated timings of every layer that has run, sorted by
+//route and then position in the chain.
+func (t *Tracer) Report() []LayerReport {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	report := make([]LayerReport, 0, len(t.stats))
+	for _, stats := range t.stats {
+		report = append(report, *stats)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Route != report[j].Route {
+			return report[i].Route < report[j].Route
+		}
+		return report[i].Position < report[j].Position
+	})
+	return report
+}
+
+var gonionPackage = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	return packagePath(runtime.FuncForPC(pc).Name())
+}()
+
+func packagePath(function string) string {
+	slash := strings.LastIndex(function, "/")
+	dot := strings.Index(function[slash+1:], ".")
+	return function[:slash+1+dot]
+}
+
+//callerOutsideGonion describes where middleware was registered by finding the
+//first caller that isn't one of the registration methods of this package.
+func callerOutsideGonion() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, gonionPackage+".(*") {
+			return path.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
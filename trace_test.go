@@ -0,0 +1,98 @@
+package gonion
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracing_TimesEveryLayer(t *testing.T) {
+	g := New()
+	tracer := g.EnableTracing()
+	var trace *Trace
+	g.Sub("/a", func(a *Composer) {
+		a.Use().Named("slow").Func(func(rw http.ResponseWriter, r *http.Request) {
+			time.Sleep(2 * time.Millisecond)
+		})
+		a.Use().ChainLink(timeoutHandler)
+		a.Get("/action", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			trace = TraceFrom(r.Context())
+			rw.Write([]byte("hello"))
+		}))
+	})
+	route := g.BuildRoutes().routeFor("GET", "/a/action")
+	recorder := httptest.NewRecorder()
+	route.Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/a/action", nil))
+	route.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a/action", nil))
+
+	assert.Equal(t, recorder.Body.String(), "timeout->hello")
+	assert.Equal(t, len(trace.Spans), 3)
+	assert.Equal(t, trace.Spans[0].Name, "slow")
+	assert.True(t, strings.HasPrefix(trace.Spans[1].Name, "trace_test.go:"))
+	assert.Equal(t, trace.Spans[2].Name, "handler")
+	assert.True(t, trace.Self(0) >= 2*time.Millisecond)
+
+	timing := recorder.Result().Trailer.Get("Server-Timing")
+	assert.True(t, strings.HasPrefix(timing, `l0;dur=`))
+	assert.True(t, strings.Contains(timing, `;desc="slow", l1;dur=`))
+	//the timeout layer writes before the handler is entered
+	timing = recorder.Result().Header.Get("Server-Timing")
+	assert.True(t, strings.HasPrefix(timing, `l0;dur=`))
+	assert.True(t, strings.Contains(timing, `;desc="slow", l1;dur=`))
+	assert.False(t, strings.Contains(timing, `desc="handler"`))
+
+	report := tracer.Report()
+	assert.Equal(t, len(report), 3)
+	assert.Equal(t, report[0].Route, "GET /a/action")
+	assert.Equal(t, report[0].Layer, "slow")
+	assert.Equal(t, report[0].Count, 2)
+	assert.True(t, report[0].Mean() >= 2*time.Millisecond)
+}
+
+func TestTracing_DisabledLeavesChainUntouched(t *testing.T) {
+	g := oneOfEachRoute()
+	var trace *Trace
+	g.Use().Func(func(rw http.ResponseWriter, r *http.Request) {
+		trace = TraceFrom(r.Context())
+	})
+	recorder := httptest.NewRecorder()
+	g.BuildRoutes().routeFor("GET", "/").Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Nil(t, trace)
+	assert.Equal(t, recorder.Header().Get("Trailer"), "")
+}
+
+func TestForkTrace_JoinsTheSpansOfTheForkedChain(t *testing.T) {
+	g := New()
+	g.EnableTracing()
+	var trace *Trace
+	g.Use().Named("fork").ChainLink(func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			trace = TraceFrom(r.Context())
+			ctx, join := ForkTrace(r.Context())
+			done := make(chan struct{})
+			go func() {
+				inner.ServeHTTP(rw, r.WithContext(ctx))
+				close(done)
+			}()
+			<-done
+			assert.Equal(t, len(trace.Spans), 1)
+			join()
+		})
+	})
+	g.Get("/", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.NotSame(t, TraceFrom(r.Context()), trace)
+	}))
+	g.BuildRoutes()[0].Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, len(trace.Spans), 2)
+	assert.Equal(t, trace.Spans[1].Name, "handler")
+	assert.False(t, trace.Spans[0].End.IsZero())
+
+	ctx, join := ForkTrace(context.Background())
+	assert.Nil(t, TraceFrom(ctx))
+	join()
+}
@@ -0,0 +1,249 @@
+package writer
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+//The combinations of optional interfaces are each a struct of only a *Writer, so
+//that storing them in an http.ResponseWriter doesn't allocate.
+
+//withInterfacesOf returns the combination with the optional interfaces of rw
+func (w *Writer) withInterfacesOf(rw http.ResponseWriter) http.ResponseWriter {
+	var mask int
+	if _, ok := rw.(http.Flusher); ok {
+		mask |= 1
+	}
+	if _, ok := rw.(http.Hijacker); ok {
+		mask |= 2
+	}
+	if _, ok := rw.(io.ReaderFrom); ok {
+		mask |= 4
+	}
+	if _, ok := rw.(http.Pusher); ok {
+		mask |= 8
+	}
+	switch mask {
+	case 1:
+		return flusherWriter{w}
+	case 2:
+		return hijackerWriter{w}
+	case 3:
+		return flusherHijackerWriter{w}
+	case 4:
+		return readerFromWriter{w}
+	case 5:
+		return flusherReaderFromWriter{w}
+	case 6:
+		return hijackerReaderFromWriter{w}
+	case 7:
+		return flusherHijackerReaderFromWriter{w}
+	case 8:
+		return pusherWriter{w}
+	case 9:
+		return flusherPusherWriter{w}
+	case 10:
+		return hijackerPusherWriter{w}
+	case 11:
+		return flusherHijackerPusherWriter{w}
+	case 12:
+		return readerFromPusherWriter{w}
+	case 13:
+		return flusherReaderFromPusherWriter{w}
+	case 14:
+		return hijackerReaderFromPusherWriter{w}
+	case 15:
+		return flusherHijackerReaderFromPusherWriter{w}
+	}
+	return w
+}
+
+type flusherWriter struct {
+	*Writer
+}
+
+func (w flusherWriter) Flush() {
+	w.flush()
+}
+
+type hijackerWriter struct {
+	*Writer
+}
+
+func (w hijackerWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+type flusherHijackerWriter struct {
+	*Writer
+}
+
+func (w flusherHijackerWriter) Flush() {
+	w.flush()
+}
+
+func (w flusherHijackerWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+type readerFromWriter struct {
+	*Writer
+}
+
+func (w readerFromWriter) ReadFrom(reader io.Reader) (int64, error) {
+	return w.readFrom(reader)
+}
+
+type flusherReaderFromWriter struct {
+	*Writer
+}
+
+func (w flusherReaderFromWriter) Flush() {
+	w.flush()
+}
+
+func (w flusherReaderFromWriter) ReadFrom(reader io.Reader) (int64, error) {
+	return w.readFrom(reader)
+}
+
+type hijackerReaderFromWriter struct {
+	*Writer
+}
+
+func (w hijackerReaderFromWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+func (w hijackerReaderFromWriter) ReadFrom(reader io.Reader) (int64, error) {
+	return w.readFrom(reader)
+}
+
+type flusherHijackerReaderFromWriter struct {
+	*Writer
+}
+
+func (w flusherHijackerReaderFromWriter) Flush() {
+	w.flush()
+}
+
+func (w flusherHijackerReaderFromWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+func (w flusherHijackerReaderFromWriter) ReadFrom(reader io.Reader) (int64, error) {
+	return w.readFrom(reader)
+}
+
+type pusherWriter struct {
+	*Writer
+}
+
+func (w pusherWriter) Push(target string, options *http.PushOptions) error {
+	return w.push(target, options)
+}
+
+type flusherPusherWriter struct {
+	*Writer
+}
+
+func (w flusherPusherWriter) Flush() {
+	w.flush()
+}
+
+func (w flusherPusherWriter) Push(target string, options *http.PushOptions) error {
+	return w.push(target, options)
+}
+
+type hijackerPusherWriter struct {
+	*Writer
+}
+
+func (w hijackerPusherWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+func (w hijackerPusherWriter) Push(target string, options *http.PushOptions) error {
+	return w.push(target, options)
+}
+
+type flusherHijackerPusherWriter struct {
+	*Writer
+}
+
+func (w flusherHijackerPusherWriter) Flush() {
+	w.flush()
+}
+
+func (w flusherHijackerPusherWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+func (w flusherHijackerPusherWriter) Push(target string, options *http.PushOptions) error {
+	return w.push(target, options)
+}
+
+type readerFromPusherWriter struct {
+	*Writer
+}
+
+func (w readerFromPusherWriter) ReadFrom(reader io.Reader) (int64, error) {
+	return w.readFrom(reader)
+}
+
+func (w readerFromPusherWriter) Push(target string, options *http.PushOptions) error {
+	return w.push(target, options)
+}
+
+type flusherReaderFromPusherWriter struct {
+	*Writer
+}
+
+func (w flusherReaderFromPusherWriter) Flush() {
+	w.flush()
+}
+
+func (w flusherReaderFromPusherWriter) ReadFrom(reader io.Reader) (int64, error) {
+	return w.readFrom(reader)
+}
+
+func (w flusherReaderFromPusherWriter) Push(target string, options *http.PushOptions) error {
+	return w.push(target, options)
+}
+
+type hijackerReaderFromPusherWriter struct {
+	*Writer
+}
+
+func (w hijackerReaderFromPusherWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+func (w hijackerReaderFromPusherWriter) ReadFrom(reader io.Reader) (int64, error) {
+	return w.readFrom(reader)
+}
+
+func (w hijackerReaderFromPusherWriter) Push(target string, options *http.PushOptions) error {
+	return w.push(target, options)
+}
+
+type flusherHijackerReaderFromPusherWriter struct {
+	*Writer
+}
+
+func (w flusherHijackerReaderFromPusherWriter) Flush() {
+	w.flush()
+}
+
+func (w flusherHijackerReaderFromPusherWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+func (w flusherHijackerReaderFromPusherWriter) ReadFrom(reader io.Reader) (int64, error) {
+	return w.readFrom(reader)
+}
+
+func (w flusherHijackerReaderFromPusherWriter) Push(target string, options *http.PushOptions) error {
+	return w.push(target, options)
+}
@@ -0,0 +1,167 @@
+package writer
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+//Writer wraps a ResponseWriter to capture the status and size of the response.
+//What's passed on to the rest of the chain is its ResponseWriter, which implements
+//exactly the optional interfaces of the wrapped writer among http.Flusher,
+//http.Hijacker, io.ReaderFrom and http.Pusher.
+type Writer struct {
+	rw           http.ResponseWriter
+	out          http.ResponseWriter
+	wrapped      http.ResponseWriter
+	status       int
+	size         int64
+	hijacked     bool
+	beforeCommit func()
+}
+
+var pool = sync.Pool{
+	New: func() interface{} {
+		return &Writer{}
+	},
+}
+
+//Wrap returns a Writer for rw from a pool, call Release once the handler
+//returns to put it back.
+func Wrap(rw http.ResponseWriter) *Writer {
+	return Through(rw, rw)
+}
+
+//Through is like Wrap, but the response is written through out, such as a writer
+//that compresses or buffers it. Hijacking and pushing use out when it implements
+//them and rw otherwise, reading from a reader is a copy to out, and flushing
+//flushes out when it's an http.Flusher and otherwise does nothing.
+func Through(rw http.ResponseWriter, out http.ResponseWriter) *Writer {
+	w := pool.Get().(*Writer)
+	w.rw = rw
+	w.out = out
+	w.wrapped = w.withInterfacesOf(rw)
+	return w
+}
+
+//Release puts the Writer back into the pool. Neither it nor its ResponseWriter
+//can be used afterwards.
+func (w *Writer) Release() {
+	*w = Writer{}
+	pool.Put(w)
+}
+
+//ResponseWriter is the writer to pass on to the rest of the chain
+func (w *Writer) ResponseWriter() http.ResponseWriter {
+	return w.wrapped
+}
+
+//Status is the status written, which is 200 when the body was written without
+//writing a status first and 0 when nothing was written yet.
+func (w *Writer) Status() int {
+	return w.status
+}
+
+//Size is the number of bytes of the body written
+func (w *Writer) Size() int64 {
+	return w.size
+}
+
+//Written is whether the response was committed by writing its status or body,
+//flushing it or hijacking the connection. Once it is, an error response can no
+//longer take its place.
+func (w *Writer) Written() bool {
+	return w.status != 0 || w.hijacked
+}
+
+//Hijacked is whether the connection was hijacked
+func (w *Writer) Hijacked() bool {
+	return w.hijacked
+}
+
+//BeforeCommit sets a hook that's called once right before the response is committed,
+//while its headers can still be changed
+func (w *Writer) BeforeCommit(hook func()) {
+	w.beforeCommit = hook
+}
+
+//commit records the status the response is committed with, calling the
+//BeforeCommit hook first
+func (w *Writer) commit(status int) {
+	if w.status != 0 {
+		return
+	}
+	if hook := w.beforeCommit; hook != nil {
+		w.beforeCommit = nil
+		hook()
+	}
+	w.status = status
+}
+
+//Header is the implementation of http.ResponseWriter
+func (w *Writer) Header() http.Header {
+	return w.out.Header()
+}
+
+//WriteHeader is the implementation of http.ResponseWriter. Informational statuses,
+//such as 103 Early Hints, don't commit the response.
+func (w *Writer) WriteHeader(status int) {
+	if status >= http.StatusOK || status == http.StatusSwitchingProtocols {
+		w.commit(status)
+	}
+	w.out.WriteHeader(status)
+}
+
+//Write is the implementation of http.ResponseWriter
+func (w *Writer) Write(data []byte) (int, error) {
+	w.commit(http.StatusOK)
+	n, err := w.out.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+//Unwrap returns the wrapped writer for http.ResponseController
+func (w *Writer) Unwrap() http.ResponseWriter {
+	return w.rw
+}
+
+func (w *Writer) flush() {
+	w.commit(http.StatusOK)
+	if flusher, ok := w.out.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *Writer) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.out.(http.Hijacker)
+	if !ok {
+		hijacker = w.rw.(http.Hijacker)
+	}
+	conn, buffer, err := hijacker.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, buffer, err
+}
+
+func (w *Writer) readFrom(reader io.Reader) (int64, error) {
+	w.commit(http.StatusOK)
+	var n int64
+	var err error
+	if w.out == w.rw {
+		n, err = w.rw.(io.ReaderFrom).ReadFrom(reader)
+	} else {
+		n, err = io.Copy(struct{ io.Writer }{w.out}, reader)
+	}
+	w.size += n
+	return n, err
+}
+
+func (w *Writer) push(target string, options *http.PushOptions) error {
+	if pusher, ok := w.out.(http.Pusher); ok {
+		return pusher.Push(target, options)
+	}
+	return w.rw.(http.Pusher).Push(target, options)
+}
@@ -0,0 +1,179 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+func (rw *plainWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+type hijackingWriter struct {
+	http.ResponseWriter
+}
+
+func (rw *hijackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func interfacesOf(rw http.ResponseWriter) []string {
+	var names []string
+	if _, ok := rw.(http.Flusher); ok {
+		names = append(names, "Flusher")
+	}
+	if _, ok := rw.(http.Hijacker); ok {
+		names = append(names, "Hijacker")
+	}
+	if _, ok := rw.(io.ReaderFrom); ok {
+		names = append(names, "ReaderFrom")
+	}
+	if _, ok := rw.(http.Pusher); ok {
+		names = append(names, "Pusher")
+	}
+	return names
+}
+
+func TestWrap_ExposesExactlyTheInterfacesOfTheWrappedWriter(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	for _, rw := range []http.ResponseWriter{
+		recorder,
+		&plainWriter{recorder},
+		&hijackingWriter{recorder},
+		struct {
+			http.ResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{},
+	} {
+		w := Wrap(rw)
+		assert.Equal(t, interfacesOf(w.ResponseWriter()), interfacesOf(rw))
+		w.Release()
+	}
+}
+
+func TestWrap_CapturesTheStatusAndSize(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := Wrap(recorder)
+	defer w.Release()
+	rw := w.ResponseWriter()
+	assert.False(t, w.Written())
+	rw.WriteHeader(http.StatusCreated)
+	rw.Write([]byte("created"))
+	assert.Equal(t, w.Status(), http.StatusCreated)
+	assert.Equal(t, w.Size(), int64(7))
+	assert.True(t, w.Written())
+}
+
+func TestWrap_CallsTheBeforeCommitHookOnce(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := Wrap(recorder)
+	defer w.Release()
+	calls := 0
+	w.BeforeCommit(func() {
+		calls++
+		w.Header().Set("X-Committed-With", "hook")
+	})
+	rw := w.ResponseWriter()
+	rw.Header().Set("Link", "</style.css>; rel=preload")
+	rw.WriteHeader(http.StatusEarlyHints)
+	assert.Equal(t, calls, 0)
+	rw.Write([]byte("a"))
+	rw.Write([]byte("b"))
+	assert.Equal(t, calls, 1)
+	assert.Equal(t, recorder.Header().Get("X-Committed-With"), "hook")
+}
+
+type upperWriter struct {
+	http.ResponseWriter
+}
+
+func (rw upperWriter) Write(data []byte) (int, error) {
+	return rw.ResponseWriter.Write(bytes.ToUpper(data))
+}
+
+func TestThrough_WritesThroughOutWithTheInterfacesOfTheWrappedWriter(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := Through(recorder, upperWriter{recorder})
+	defer w.Release()
+	assert.Equal(t, interfacesOf(w.ResponseWriter()), interfacesOf(recorder))
+	io.Copy(w.ResponseWriter(), strings.NewReader("through"))
+	w.ResponseWriter().(http.Flusher).Flush()
+	assert.Equal(t, recorder.Body.String(), "THROUGH")
+	assert.Equal(t, w.Size(), int64(7))
+	assert.False(t, recorder.Flushed)
+}
+
+//guardedWriter hijacks for the writer it guards, like the writer of the timeout middleware
+type guardedWriter struct {
+	http.ResponseWriter
+	hijacks int
+}
+
+func (rw *guardedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rw.hijacks++
+	return rw.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func TestThrough_HijacksThroughOutWhenItCan(t *testing.T) {
+	rw := &hijackingWriter{httptest.NewRecorder()}
+	out := &guardedWriter{ResponseWriter: rw}
+	w := Through(rw, out)
+	defer w.Release()
+	_, _, err := w.ResponseWriter().(http.Hijacker).Hijack()
+	assert.Nil(t, err)
+	assert.Equal(t, out.hijacks, 1)
+	assert.True(t, w.Hijacked())
+}
+
+func TestWrap_SupportsResponseController(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := Wrap(&plainWriter{recorder})
+	defer w.Release()
+	err := http.NewResponseController(w.ResponseWriter()).Flush()
+	assert.Nil(t, err)
+	assert.True(t, recorder.Flushed)
+}
+
+func TestWrap_CountsBytesReadFrom(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w := Wrap(rw)
+		defer w.Release()
+		io.Copy(w.ResponseWriter(), strings.NewReader("streamed"))
+		assert.Equal(t, w.Size(), int64(8))
+		assert.Equal(t, w.Status(), http.StatusOK)
+		_, ok := w.ResponseWriter().(io.ReaderFrom)
+		assert.True(t, ok)
+	}))
+	defer server.Close()
+	response, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(response.Body)
+	assert.Equal(t, string(body), "streamed")
+}
+
+func TestWrap_DoesNotAllocate(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	body := []byte("ok")
+	allocs := testing.AllocsPerRun(100, func() {
+		w := Wrap(recorder)
+		rw := w.ResponseWriter()
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(body)
+		w.Release()
+	})
+	assert.Equal(t, allocs, float64(0))
+}